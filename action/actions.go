@@ -2,12 +2,18 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,11 +32,32 @@ func CreateApplication(cfgPath string) func(ctx *cli.Context) error {
 		if _, err := os.Stat(AppPath(cfgPath, ctx.Args().Get(0))); err == nil {
 			return errors.New("application already exists")
 		}
-		err := WriteAppFiles(cfgPath, &AppInfo{
+		tlsinfo, err := tlsFromFlags(ctx, TLSInfo{})
+		if err != nil {
+			return err
+		}
+		proxyinfo, err := proxyFromFlags(ctx, ProxyInfo{})
+		if err != nil {
+			return err
+		}
+		grpcinfo, err := grpcFromFlags(ctx, GRPCInfo{})
+		if err != nil {
+			return err
+		}
+		defaults, err := defaultsFromFlags(ctx, RequestDefaults{})
+		if err != nil {
+			return err
+		}
+		err = WriteAppFiles(cfgPath, &AppInfo{
 			Version:     "1",
 			Name:        ctx.Args().Get(0),
 			Description: ctx.String("description"),
 			Host:        ctx.String("host"),
+			TLS:         tlsinfo,
+			Proxy:       proxyinfo,
+			GRPC:        grpcinfo,
+			Hooks:       hooksFromFlags(ctx, Hooks{}),
+			Defaults:    defaults,
 		})
 		if err != nil {
 			return err
@@ -56,30 +83,111 @@ func CreateRequest(cfgPath string) func(ctx *cli.Context) error {
 		if !valid(app) || !appExists(cfgPath, app) {
 			return errors.New("application does not exist")
 		}
-		if !valid(reqName) {
-			return errors.New("request name must only contain letters, numbers, dashes and underscores")
+		if !validRequest(reqName) {
+			return errors.New("request name must only contain letters, numbers, dashes and underscores, with slashes between groups")
 		}
 
 		path := ReqPath(cfgPath, app, reqName)
 		if _, err := os.Stat(path); err == nil {
 			return errors.New("request already exists")
 		}
+		if err := nameConflict(cfgPath, app, reqName, false); err != nil {
+			return err
+		}
 
-		if err := WriteRequestFiles(cfgPath, app, &RequestInfo{
+		headers, err := parseHeaders(ctx.StringSlice("header"))
+		if err != nil {
+			return err
+		}
+		query, err := parseKeyValues(ctx.StringSlice("query"))
+		if err != nil {
+			return err
+		}
+		reqinfo := &RequestInfo{
 			Version:     "1",
 			Name:        reqName,
 			Description: ctx.String("description"),
 			Method:      ctx.String("method"),
 			Path:        ctx.String("path"),
-			Headers:     ctx.StringSlice("header"),
+			Headers:     headers,
 			Body:        ctx.String("body"),
-		}); err != nil {
+			Query:       query,
+		}
+		if err := retryFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := checksFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := kindFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := graphqlFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := exampleFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		reqinfo.Hooks = hooksFromFlags(ctx, reqinfo.Hooks)
+		if err := unsetFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := WriteRequestFiles(cfgPath, app, reqinfo); err != nil {
 			return err
 		}
 		fmt.Printf("Created request %s\n", reqName)
 		return nil
 	}
 }
+
+// Creates a group of requests, which is also created by creating a request in
+// it. Its headers and variables apply to every request it holds.
+func CreateGroup(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("create group must have exactly one argument")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		group := ctx.Args().Get(0)
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		if !validRequest(group) {
+			return errors.New("group name must only contain letters, numbers, dashes and underscores, with slashes between groups")
+		}
+		if _, err := os.Stat(GroupPath(cfgPath, app, group)); err == nil {
+			return errors.New("group already exists")
+		}
+		if err := nameConflict(cfgPath, app, group, true); err != nil {
+			return err
+		}
+		headers, err := parseHeaders(ctx.StringSlice("header"))
+		if err != nil {
+			return err
+		}
+		vars, err := parseVars(ctx.StringSlice("var"))
+		if err != nil {
+			return err
+		}
+		groupinfo := &GroupInfo{
+			Version:     "1",
+			Name:        group,
+			Description: ctx.String("description"),
+			Headers:     headers,
+		}
+		if len(vars) > 0 {
+			groupinfo.Variables = vars
+		}
+		if err := WriteGroupInfo(cfgPath, app, groupinfo); err != nil {
+			return err
+		}
+		fmt.Printf("Created group %s\n", group)
+		return nil
+	}
+}
 func EditApplication(cfgPath string) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		if ctx.NArg() != 1 {
@@ -103,6 +211,23 @@ func EditApplication(cfgPath string) func(ctx *cli.Context) error {
 		if ctx.String("host") != "" {
 			appinfo.Host = ctx.String("host")
 		}
+		appinfo.TLS, err = tlsFromFlags(ctx, appinfo.TLS)
+		if err != nil {
+			return err
+		}
+		appinfo.Proxy, err = proxyFromFlags(ctx, appinfo.Proxy)
+		if err != nil {
+			return err
+		}
+		appinfo.GRPC, err = grpcFromFlags(ctx, appinfo.GRPC)
+		if err != nil {
+			return err
+		}
+		appinfo.Hooks = hooksFromFlags(ctx, appinfo.Hooks)
+		appinfo.Defaults, err = defaultsFromFlags(ctx, appinfo.Defaults)
+		if err != nil {
+			return err
+		}
 		err = WriteAppFiles(cfgPath, appinfo)
 		if err != nil {
 			return err
@@ -127,7 +252,7 @@ func EditRequest(cfgPath string) func(ctx *cli.Context) error {
 		if !valid(app) || !appExists(cfgPath, app) {
 			return errors.New("application does not exist")
 		}
-		if !valid(reqName) {
+		if !validRequest(reqName) {
 			return errors.New("request name is invalid")
 		}
 
@@ -154,8 +279,46 @@ func EditRequest(cfgPath string) func(ctx *cli.Context) error {
 		if ctx.String("body") != "" {
 			reqinfo.Body = ctx.String("body")
 		}
-		if ctx.StringSlice("header") != nil {
-			reqinfo.Headers = ctx.StringSlice("header")
+		reqinfo.Headers, err = headersFromFlags(ctx, reqinfo.Headers)
+		if err != nil {
+			return err
+		}
+		query, err := parseKeyValues(ctx.StringSlice("query"))
+		if err != nil {
+			return err
+		}
+		reqinfo.Query = mergeQuery(reqinfo.Query, query)
+		for _, k := range ctx.StringSlice("remove-query") {
+			delete(reqinfo.Query, k)
+		}
+		if err := retryFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := checksFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := kindFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := graphqlFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if err := exampleFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		reqinfo.Hooks = hooksFromFlags(ctx, reqinfo.Hooks)
+		if err := unsetFromFlags(ctx, reqinfo); err != nil {
+			return err
+		}
+		if ctx.Bool("interactive") {
+			reqinfo, err = editInteractively(reqinfo)
+			if err != nil {
+				return err
+			}
+			if reqinfo == nil {
+				fmt.Println("edit aborted")
+				return nil
+			}
 		}
 
 		if err := WriteRequestFiles(cfgPath, app, reqinfo); err != nil {
@@ -165,6 +328,50 @@ func EditRequest(cfgPath string) func(ctx *cli.Context) error {
 	}
 }
 
+func EditGroup(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("edit group must have exactly one argument")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		group := ctx.Args().Get(0)
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		if !validRequest(group) {
+			return errors.New("group name is invalid")
+		}
+		groupinfo, err := readGroupInfo(cfgPath, app, group)
+		if err != nil {
+			return err
+		}
+		if ctx.String("description") != "" {
+			groupinfo.Description = ctx.String("description")
+		}
+		groupinfo.Headers, err = headersFromFlags(ctx, groupinfo.Headers)
+		if err != nil {
+			return err
+		}
+		vars, err := parseVars(ctx.StringSlice("var"))
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			if groupinfo.Variables == nil {
+				groupinfo.Variables = make(map[string]string)
+			}
+			groupinfo.Variables[k] = v
+		}
+		for _, k := range ctx.StringSlice("remove-var") {
+			delete(groupinfo.Variables, k)
+		}
+		return WriteGroupInfo(cfgPath, app, groupinfo)
+	}
+}
+
 func DeleteApplication(cfgPath string) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		if ctx.NArg() < 1 || ctx.NArg() > 1 {
@@ -179,20 +386,22 @@ func DeleteApplication(cfgPath string) func(ctx *cli.Context) error {
 
 		_path := AppPath(cfgPath, app)
 
-		files, err := os.ReadDir(_path)
+		reqs, err := readRequestFiles(cfgPath, app)
 		if err != nil {
 			return errors.New("application does not exist")
 		}
 		fmt.Printf(
 			"You are about to delete the application %s and %d associated request(s).\nThis action cannot be undone.\n",
 			app,
-			len(files)-1, // -1 because .appinfo isnt a req
+			len(reqs),
 		)
 		if ctx.Bool("confirm") || ConfirmPrompt() {
 			err := os.RemoveAll(_path)
 			if err != nil {
 				return err
 			}
+			os.RemoveAll(path.Dir(SnapshotPath(cfgPath, app, "")))
+			os.Remove(VariablesPath(cfgPath, app))
 			fmt.Print("application " + app + " has been deleted")
 			return nil
 		}
@@ -214,7 +423,7 @@ func DeleteRequest(cfgPath string) func(ctx *cli.Context) error {
 
 		reqName := ctx.Args().Get(0)
 
-		if !valid(reqName) {
+		if !validRequest(reqName) {
 			return errors.New("request name is invalid")
 		}
 		if !valid(app) || !appExists(cfgPath, app) {
@@ -237,6 +446,7 @@ func DeleteRequest(cfgPath string) func(ctx *cli.Context) error {
 			if err != nil {
 				return err
 			}
+			os.Remove(SnapshotPath(cfgPath, app, reqName))
 			fmt.Print("request " + reqName + " has been deleted")
 			return nil
 		}
@@ -245,6 +455,48 @@ func DeleteRequest(cfgPath string) func(ctx *cli.Context) error {
 	}
 }
 
+func DeleteGroup(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("delete group must have exactly one argument")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		group := ctx.Args().Get(0)
+		if !validRequest(group) {
+			return errors.New("group name is invalid")
+		}
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		if _, err := readGroupInfo(cfgPath, app, group); err != nil {
+			return err
+		}
+		nodes, err := readRequestTree(cfgPath, app, group)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(
+			"You are about to delete the group %s in application %s and %d associated request(s).\nThis action cannot be undone.\n",
+			group,
+			app,
+			len(flattenRequestTree(nil, nodes)),
+		)
+		if ctx.Bool("confirm") || ConfirmPrompt() {
+			if err := os.RemoveAll(GroupPath(cfgPath, app, group)); err != nil {
+				return err
+			}
+			os.RemoveAll(path.Join(path.Dir(SnapshotPath(cfgPath, app, "")), group))
+			fmt.Print("group " + group + " has been deleted")
+			return nil
+		}
+		fmt.Println("delete aborted")
+		return nil
+	}
+}
+
 func Switch(cfgPath string) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		if ctx.NArg() < 1 {
@@ -260,7 +512,7 @@ func Switch(cfgPath string) func(ctx *cli.Context) error {
 		if _, err := os.Stat(AppPath(cfgPath, app)); err != nil {
 			return errors.New("application does not exist")
 		}
-		err := os.WriteFile(path.Join(cfgPath, "current_app"), []byte(ctx.Args().First()), 0700)
+		err := writePrivateFile(path.Join(cfgPath, "current_app"), []byte(ctx.Args().First()))
 		if err != nil {
 			return err
 		}
@@ -269,6 +521,37 @@ func Switch(cfgPath string) func(ctx *cli.Context) error {
 	}
 }
 
+func Configure(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 0 {
+			return errors.New("config does not take any arguments")
+		}
+		cfg, err := ReadConfig(cfgPath)
+		if err != nil {
+			return err
+		}
+		if ctx.NumFlags() == 0 {
+			out, err := yaml.Marshal(cfg)
+			if err != nil {
+				return errors.New("failed to generate command output")
+			}
+			fmt.Print(string(out))
+			return nil
+		}
+		cfg.Proxy, err = proxyFromFlags(ctx, cfg.Proxy)
+		if err != nil {
+			return err
+		}
+		if ctx.IsSet("history-limit") {
+			cfg.HistoryLimit = ctx.Int("history-limit")
+		}
+		if ctx.IsSet("history-values") {
+			cfg.HistoryValues = ctx.Bool("history-values")
+		}
+		return WriteConfig(cfgPath, cfg)
+	}
+}
+
 func ListApplications(cfgPath string) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		_path := AppPath(cfgPath, "")
@@ -304,35 +587,24 @@ func ListRequests(cfgPath string) func(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		_path := AppPath(cfgPath, app)
-		files, err := os.ReadDir(_path)
+		nodes, err := readRequestTree(cfgPath, app, "")
 		if err != nil {
 			return err
 		}
-		reqs := ""
-		for _, file := range files {
-			if file.Name() == ".appinfo" {
-				continue
-			}
-			reqinfo := new(RequestInfo)
-			contents, err := os.ReadFile(path.Join(AppPath(cfgPath, app), file.Name()))
-			if err != nil {
-				return errors.New("failed to read request info")
-			}
-			err = yaml.Unmarshal(contents, reqinfo)
-			if err != nil {
-				return errors.New("request file is malformed or corrupted")
-			}
-			if reqinfo.Description == "" {
-				reqs += reqinfo.Name + "\n"
-			} else {
-				reqs += reqinfo.Name + ": " + reqinfo.Description + "\n"
-			}
-		}
-		fmt.Print(reqs)
+		fmt.Print(renderRequestTree(nodes))
 		return nil
 	}
 }
+
+// Lists nodes one per line as "- name", indenting the contents of groups
+func listNodes(nodes []*requestNode, indent string) string {
+	out := ""
+	for _, node := range nodes {
+		out += indent + "- " + node.label() + "\n" + listNodes(node.children, indent+"\t")
+	}
+	return out
+}
+
 func ListAll(cfgPath string) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		apps, err := os.ReadDir(AppPath(cfgPath, ""))
@@ -345,34 +617,16 @@ func ListAll(cfgPath string) func(ctx *cli.Context) error {
 			return nil
 		}
 		for _, app := range apps {
-			reqfiles, err := os.ReadDir(AppPath(cfgPath, app.Name()))
+			nodes, err := readRequestTree(cfgPath, app.Name(), "")
 			if err != nil {
 				return err
 			}
-			if len(reqfiles) <= 1 {
+			if len(nodes) == 0 {
 				output += app.Name() + "\n"
 			} else {
 				output += app.Name() + ":\n"
 			}
-			for _, reqfile := range reqfiles {
-				if reqfile.Name() == ".appinfo" {
-					continue
-				}
-				reqinfo := new(RequestInfo)
-				contents, err := os.ReadFile(path.Join(AppPath(cfgPath, app.Name()), reqfile.Name()))
-				if err != nil {
-					return errors.New("failed to read request info")
-				}
-				err = yaml.Unmarshal(contents, reqinfo)
-				if err != nil {
-					return errors.New("request file is malformed or corrupted")
-				}
-				if reqinfo.Description == "" {
-					output += "\t- " + reqinfo.Name + "\n"
-				} else {
-					output += "\t- " + reqinfo.Name + ": " + reqinfo.Description + "\n"
-				}
-			}
+			output += listNodes(nodes, "\t")
 		}
 		fmt.Print(output)
 		return nil
@@ -417,13 +671,38 @@ func InfoRequest(cfgPath string) func(ctx *cli.Context) error {
 		if !valid(app) {
 			return errors.New("application name is invalid")
 		}
-		if !valid(ctx.Args().Get(0)) {
+		if !validRequest(ctx.Args().Get(0)) {
 			return errors.New("request name is invalid")
 		}
 		contents, err := os.ReadFile(ReqPath(cfgPath, app, ctx.Args().Get(0)))
 		if err != nil {
 			return errors.New("failed to read request info")
 		}
+		// with the defaults of the app and groups merged in, as the request is called
+		if ctx.Bool("resolved") {
+			appinfo, err := readAppInfo(cfgPath, app)
+			if err != nil {
+				return err
+			}
+			reqinfo, err := readRequestInfo(cfgPath, app, ctx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			groups, err := requestGroups(cfgPath, app, ctx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			vars, err := groupVariables(cfgPath, app, groups)
+			if err != nil {
+				return err
+			}
+			if reqinfo, err = resolveRequest(appinfo, groups, reqinfo, vars); err != nil {
+				return err
+			}
+			if contents, err = yaml.Marshal(reqinfo); err != nil {
+				return errors.New("failed to generate command output")
+			}
+		}
 		fileLines := strings.Split(string(contents), "\n")
 		fmt.Println(ctx.Args().Get(0) + ":\n\t" + strings.Join(fileLines[2:len(fileLines)-1], "\n\t"))
 		return nil
@@ -437,15 +716,27 @@ type VerboseCallResponse struct {
 	Latency      string            `yaml:"Latency"`
 	Status       string            `yaml:"Status"`
 	ResponseBody string            `yaml:"ResponseBody"`
+	Attempts     []CallAttempt     `yaml:"Attempts,omitempty"`
+}
+
+// Outcome of a single attempt when a call is retried
+type CallAttempt struct {
+	Attempt int    `yaml:"Attempt"`
+	Status  string `yaml:"Status,omitempty"`
+	Error   string `yaml:"Error,omitempty"`
+	Latency string `yaml:"Latency"`
 }
 
+// Calls one or more requests. Several requests, or every request of an app with
+// --all, are called concurrently and their output is prefixed with their names.
+// With --data a request is called once for every row of a file.
 func Call(cfgPath string, httpClient http.Client) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
-		if ctx.NArg() < 1 {
-			return errors.New("expected argument")
+		if ctx.Bool("all") && ctx.NArg() > 0 {
+			return errors.New("--all cannot be used with request names")
 		}
-		if ctx.NArg() > 1 {
-			return errors.New("expected exactly one argument")
+		if !ctx.Bool("all") && ctx.NArg() < 1 {
+			return errors.New("expected argument")
 		}
 		app, err := getApp(cfgPath, ctx)
 		if err != nil {
@@ -454,78 +745,861 @@ func Call(cfgPath string, httpClient http.Client) func(ctx *cli.Context) error {
 		if !valid(app) {
 			return errors.New("application name is invalid")
 		}
-		if !valid(ctx.Args().Get(0)) {
-			return errors.New("request name is invalid")
-		}
-		// retrieve app information
-		contents, err := os.ReadFile(AppInfoFilePath(cfgPath, app))
-		if err != nil {
-			return errors.New("failed to retrieve app information")
-		}
-		appinfo := new(AppInfo)
-		err = yaml.Unmarshal(contents, appinfo)
-		if err != nil {
-			return errors.New("appinfo file is malformed or corrupted")
-		}
-		// retrieve request information
-		contents, err = os.ReadFile(ReqPath(cfgPath, app, ctx.Args().Get(0)))
-		if err != nil {
-			return errors.New("failed to retrieve request information")
-		}
-		reqinfo := new(RequestInfo)
-		err = yaml.Unmarshal(contents, reqinfo)
-		if err != nil {
-			return errors.New("request file is malformed or corrupted")
+		names := ctx.Args().Slice()
+		if ctx.Bool("all") {
+			reqs, err := readRequestFiles(cfgPath, app)
+			if err != nil {
+				return err
+			}
+			for _, reqinfo := range reqs {
+				names = append(names, reqinfo.Name)
+			}
+			if len(names) == 0 {
+				return errors.New("application does not have any requests")
+			}
 		}
-		body := bytes.NewBuffer([]byte(reqinfo.Body))
-		req, err := http.NewRequest(reqinfo.Method, appinfo.Host+reqinfo.Path, body)
-		if err != nil {
-			return errors.New("failed to create web request")
+		for _, name := range names {
+			if !validRequest(name) {
+				return errors.New("request name is invalid")
+			}
 		}
-		for _, header := range reqinfo.Headers {
-			h := strings.Split(header, ": ")
-			if len(h) < 2 {
-				return errors.New("malformed header(s)")
+		if ctx.String("data") != "" {
+			if len(names) > 1 || ctx.Bool("all") {
+				return errors.New("--data can only be used with a single request")
 			}
-			req.Header.Add(h[0], h[1])
+			return callData(cfgPath, ctx, httpClient, app, names[0])
 		}
-		if ctx.Bool("no-redirect") {
-			httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+		if ctx.IsSet("results") {
+			return errors.New("--results can only be used with --data")
 		}
-		t1 := time.Now()
-		resp, err := httpClient.Do(req)
-		t2 := time.Now()
-		if err != nil {
-			return errors.New("failed to send web request")
+		if len(names) == 1 && !ctx.Bool("all") {
+			return callOne(cfgPath, ctx, httpClient, app, &callRun{name: names[0]}, os.Stdout, os.Stderr)
 		}
-		defer resp.Body.Close()
-		if ctx.Bool("fail") && resp.StatusCode >= 400 {
-			return errors.New("")
+		runs := make([]*callRun, len(names))
+		for i, name := range names {
+			runs[i] = &callRun{name: name, label: name}
 		}
-		respBody, err := io.ReadAll(resp.Body)
+		failed, err := callBatch(cfgPath, ctx, httpClient, app, runs)
 		if err != nil {
-			return errors.New("failed to read response body")
+			return err
 		}
-		if !ctx.Bool("verbose") {
-			fmt.Print(string(respBody) + "\n")
-		} else if req.Method == "GET" {
-			var out VerboseCallResponse
-			out.Request = req.Method + " " + req.URL.String()
-			out.RequestBody = reqinfo.Body
-			out.Headers = make(map[string]string)
-			for _, header := range reqinfo.Headers {
-				k := strings.Split(header, ": ")[0]
-				v := strings.Split(header, ": ")[1]
-				out.Headers[k] = v
-			}
-			out.Latency = fmt.Sprintf("%v", t2.Sub(t1))
-			out.Status = resp.Status
-			out.ResponseBody = string(respBody)
-			o, err := yaml.Marshal(out)
-			if err != nil {
-				return errors.New("failed to generate command output")
+		if failed > 0 {
+			return fmt.Errorf("%d of %d requests failed", failed, len(runs))
+		}
+		return nil
+	}
+}
+
+// Calls a single request, writing its output to stdout and stderr and the
+// outcome to run
+func callOne(cfgPath string, ctx *cli.Context, httpClient http.Client, app string, run *callRun, stdout, stderr io.Writer) error {
+	appinfo, err := readAppInfo(cfgPath, app)
+	if err != nil {
+		return err
+	}
+	reqinfo, err := readRequestInfo(cfgPath, app, run.name)
+	if err != nil {
+		return err
+	}
+	groups, err := requestGroups(cfgPath, app, run.name)
+	if err != nil {
+		return err
+	}
+	flagVars, err := parseVars(ctx.StringSlice("var"))
+	if err != nil {
+		return err
+	}
+	// --var overrides variables captured by earlier calls
+	vars, err := groupVariables(cfgPath, app, groups)
+	if err != nil {
+		return err
+	}
+	for k, v := range flagVars {
+		vars[k] = v
+	}
+	for k, v := range run.row {
+		vars[k] = v
+	}
+	reqinfo, err = resolveRequest(appinfo, groups, reqinfo, vars)
+	if err != nil {
+		return err
+	}
+	query, err := parseKeyValues(ctx.StringSlice("query"))
+	if err != nil {
+		return err
+	}
+	reqinfo.Query = mergeQuery(reqinfo.Query, query)
+	if ctx.Bool("dry-run") && (reqinfo.Kind == "websocket" || reqinfo.Kind == "grpc") {
+		return errors.New("--dry-run only supports http and graphql requests")
+	}
+	if reqinfo.Kind == "websocket" {
+		return callWebSocket(cfgPath, ctx, stdout, appinfo, reqinfo, vars)
+	}
+	if reqinfo.Kind == "grpc" {
+		return callGRPC(cfgPath, ctx, stdout, appinfo, reqinfo, vars)
+	}
+	var req *http.Request
+	if reqinfo.Kind == "graphql" {
+		req, err = newGraphQLRequest(appinfo, reqinfo, vars)
+	} else {
+		req, err = newRequest(appinfo, reqinfo, vars)
+	}
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("snapshot") && ctx.Bool("compare") {
+		return errors.New("--snapshot and --compare cannot be used together")
+	}
+	if _, err := parsePaths(ctx.StringSlice("ignore")); err != nil {
+		return err
+	}
+	if ctx.IsSet("select") && ctx.Bool("verbose") {
+		return errors.New("--select and --verbose cannot be used together")
+	}
+	file, err := outputPath(ctx, req)
+	if err != nil {
+		return err
+	}
+	if file != "" && (ctx.IsSet("select") || ctx.Bool("snapshot") || ctx.Bool("compare")) {
+		return errors.New("responses saved to a file cannot be selected, snapshotted or compared")
+	}
+	var offset int64
+	if file != "" && ctx.Bool("continue") {
+		offset = requestResume(req, file)
+	}
+	stream := ctx.Bool("stream")
+	if stream && (file != "" || ctx.IsSet("select") || ctx.Bool("snapshot") || ctx.Bool("compare") || ctx.Bool("verbose")) {
+		return errors.New("--stream cannot be used with --output-file, --select, --snapshot, --compare or --verbose")
+	}
+	if err := validDuration("stream-timeout", ctx.String("stream-timeout")); err != nil {
+		return err
+	}
+	// values captured from --data rows are written to the results instead
+	saveVars := func(set map[string]string) error {
+		if run.row == nil {
+			return storeVariables(cfgPath, app, set)
+		}
+		if run.captured == nil {
+			run.captured = make(map[string]string)
+		}
+		for k, v := range set {
+			run.captured[k] = v
+		}
+		return nil
+	}
+	// pre_request hooks run on dry runs as well, since they may sign the
+	// request, but the variables they set are not stored
+	hookVars, err := preRequestHooks(appinfo, reqinfo, req, vars, stderr)
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("dry-run") {
+		// the request as the transport would write it, without sending it
+		dump, err := httputil.DumpRequestOut(req, true)
+		if err != nil {
+			return errors.New("failed to print request")
+		}
+		stdout.Write(dump)
+		if len(dump) > 0 && dump[len(dump)-1] != '\n' {
+			fmt.Fprintln(stdout)
+		}
+		return nil
+	}
+	if len(hookVars) > 0 {
+		if err := saveVars(hookVars); err != nil {
+			return err
+		}
+	}
+	if stream && ctx.String("stream-timeout") != "" {
+		d, _ := time.ParseDuration(ctx.String("stream-timeout"))
+		c, cancel := context.WithTimeout(req.Context(), d)
+		defer cancel()
+		req = req.WithContext(c)
+	}
+	var snapshot *Snapshot
+	if ctx.Bool("compare") {
+		snapshot, err = ReadSnapshot(cfgPath, app, reqinfo.Name)
+		if err != nil {
+			return err
+		}
+	}
+	httpClient.Transport, err = newTransport(cfgPath, ctx, httpClient.Transport, appinfo)
+	if err != nil {
+		return err
+	}
+	if ctx.Bool("no-redirect") {
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+	}
+	httpClient.Timeout, err = callTimeout(ctx, reqinfo)
+	if err != nil {
+		return err
+	}
+	if stream {
+		// streams may stay open indefinitely, so the timeout only
+		// applies until the response headers arrive
+		if tr, ok := httpClient.Transport.(*http.Transport); ok {
+			tr.ResponseHeaderTimeout = httpClient.Timeout
+		}
+		httpClient.Timeout = 0
+	}
+	retry, err := newRetryPolicy(ctx, reqinfo, req)
+	if err != nil {
+		return err
+	}
+	if httpClient.Timeout > 0 {
+		// the timeout also covers every retry and the waits between them
+		c, cancel := context.WithTimeout(req.Context(), httpClient.Timeout)
+		defer cancel()
+		req = req.WithContext(c)
+	}
+	var (
+		resp     *http.Response
+		attempts []CallAttempt
+		t1, t2   time.Time
+	)
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if req.GetBody != nil {
+				req.Body, _ = req.GetBody()
+			}
+		}
+		t1 = time.Now()
+		resp, err = httpClient.Do(req)
+		t2 = time.Now()
+		a := CallAttempt{Attempt: attempt + 1, Latency: fmt.Sprintf("%v", t2.Sub(t1))}
+		if err != nil {
+			a.Error = err.Error()
+		} else {
+			a.Status = resp.Status
+		}
+		attempts = append(attempts, a)
+		if !retry.shouldRetry(attempt, resp, err) {
+			break
+		}
+		c, stop := signal.NotifyContext(req.Context(), os.Interrupt)
+		werr := retry.wait(c, attempt)
+		stop()
+		if errors.Is(werr, context.DeadlineExceeded) {
+			// no time is left for another attempt, so the last one stands
+			break
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if werr != nil {
+			return errors.New("call was interrupted while waiting to retry")
+		}
+	}
+	if err != nil {
+		recordHistory(cfgPath, newHistoryEntry(app, reqinfo.Name, vars, req, nil, nil, t2.Sub(t1), err))
+		if len(attempts) > 1 {
+			return fmt.Errorf("failed to send web request after %d attempts", len(attempts))
+		}
+		return errors.New("failed to send web request")
+	}
+	defer resp.Body.Close()
+	var (
+		respBody []byte
+		size     int64
+	)
+	if file != "" {
+		// only the start of the body is kept in memory
+		respBody, size, err = saveBody(resp, file, offset, ctx.Bool("fail"))
+	} else if stream {
+		head := &limitedBuffer{limit: maxHistoryBody}
+		_, err = streamBody(io.TeeReader(resp.Body, head), resp.Header.Get("Content-Type"), stdout, ctx.Bool("raw"), ctx.Int("max-events"))
+		respBody, size = head.Bytes(), head.total
+	} else {
+		respBody, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		if file != "" {
+			return err
+		}
+		return errors.New("failed to read response body")
+	}
+	entry := newHistoryEntry(app, reqinfo.Name, vars, req, resp, respBody, t2.Sub(t1), nil)
+	entry.Truncated = entry.Truncated || size > int64(len(respBody))
+	recordHistory(cfgPath, entry)
+	run.status = resp.StatusCode
+	// captures are only saved from responses that pass every assertion
+	var assertErr error
+	if failures := checkAssertions(reqinfo.Assert, resp.StatusCode, respBody); len(failures) > 0 {
+		for _, f := range failures {
+			fmt.Fprintln(stderr, "assertion failed: "+f)
+		}
+		assertErr = fmt.Errorf("%d of %d assertion(s) failed", len(failures), len(reqinfo.Assert))
+	} else if len(reqinfo.Capture) > 0 {
+		captured, err := evalCaptures(reqinfo.Capture, respBody)
+		if err != nil {
+			return err
+		}
+		if err := saveVars(captured); err != nil {
+			return err
+		}
+	}
+	// a failing post_response hook fails the call like an assertion
+	if hookVars, err := postResponseHooks(appinfo, reqinfo, resp, respBody, t2.Sub(t1), vars, stderr); err != nil {
+		if assertErr == nil {
+			assertErr = err
+		}
+	} else if len(hookVars) > 0 {
+		if err := saveVars(hookVars); err != nil {
+			return err
+		}
+	}
+	if snapshot != nil {
+		if err := compareSnapshot(stdout, snapshot, resp, respBody, ctx.StringSlice("ignore")); err != nil {
+			return err
+		}
+		return assertErr
+	}
+	if ctx.Bool("snapshot") {
+		err := WriteSnapshot(cfgPath, app, reqinfo.Name, newSnapshot(resp, respBody, ctx.StringSlice("ignore")))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stderr, "Saved snapshot of "+reqinfo.Name)
+	}
+	if ctx.Bool("fail") && resp.StatusCode >= 400 {
+		return errors.New("")
+	}
+	if file != "" {
+		fmt.Fprintf(stderr, "Saved %s to %s\n", formatBytes(size), file)
+		return assertErr
+	}
+	if stream {
+		// already printed as it arrived
+		return assertErr
+	}
+	if ctx.IsSet("select") {
+		selected, err := selectFromBody(respBody, ctx.String("select"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, strings.Join(selected, "\n"))
+	} else if reqinfo.Kind == "graphql" && !ctx.Bool("verbose") {
+		if printGraphQL(ctx, stdout, stderr, respBody, resp.Header.Get("Content-Type")) > 0 && ctx.Bool("fail") {
+			return errors.New("response contains GraphQL errors")
+		}
+	} else if !ctx.Bool("verbose") {
+		if err := checkPrintable(respBody, resp.Header.Get("Content-Type")); err != nil {
+			return err
+		}
+		printBody(ctx, stdout, respBody, resp.Header.Get("Content-Type"))
+	} else {
+		var out VerboseCallResponse
+		out.Request = req.Method + " " + req.URL.String()
+		if req.GetBody != nil {
+			body, _ := req.GetBody()
+			b, _ := io.ReadAll(body)
+			out.RequestBody = string(b)
+		}
+		out.Headers = make(map[string]string)
+		for _, header := range reqinfo.Headers {
+			out.Headers[header.Name] = req.Header.Get(header.Name)
+		}
+		out.Latency = fmt.Sprintf("%v", t2.Sub(t1))
+		out.Status = resp.Status
+		out.ResponseBody = string(respBody)
+		if !ctx.Bool("raw") {
+			out.ResponseBody = string(formatBody(respBody, resp.Header.Get("Content-Type"), false))
+		}
+		if retry.retries > 0 {
+			out.Attempts = attempts
+		}
+		o, err := yaml.Marshal(out)
+		if err != nil {
+			return errors.New("failed to generate command output")
+		}
+		fmt.Fprint(stdout, string(o))
+	}
+	return assertErr
+}
+
+func HistoryList(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 0 {
+			return errors.New("history list does not take any arguments")
+		}
+		entries, err := ReadHistory(cfgPath)
+		if err != nil {
+			return err
+		}
+		if ctx.String("app") != "" {
+			filtered := entries[:0]
+			for _, entry := range entries {
+				if entry.App == ctx.String("app") {
+					filtered = append(filtered, entry)
+				}
+			}
+			entries = filtered
+		}
+		if n := ctx.Int("limit"); n > 0 && len(entries) > n {
+			entries = entries[len(entries)-n:]
+		}
+		if len(entries) < 1 {
+			fmt.Println("No calls in history")
+			return nil
+		}
+		output := ""
+		for _, entry := range entries {
+			status := entry.Status
+			if entry.Error != "" {
+				status = "error"
+			}
+			output += fmt.Sprintf("%d\t%s\t%s/%s\t%s %s\t%s\t%s\n",
+				entry.ID,
+				entry.Timestamp.Local().Format("2006-01-02 15:04:05"),
+				entry.App,
+				entry.Request,
+				entry.Method,
+				entry.URL,
+				status,
+				entry.Latency,
+			)
+		}
+		fmt.Print(output)
+		return nil
+	}
+}
+
+// Reads the entry named by the command's only argument
+func historyEntryArg(cfgPath string, ctx *cli.Context) (*HistoryEntry, error) {
+	if ctx.NArg() < 1 {
+		return nil, errors.New("expected argument")
+	}
+	if ctx.NArg() > 1 {
+		return nil, errors.New("expected exactly one argument")
+	}
+	id, err := strconv.Atoi(ctx.Args().Get(0))
+	if err != nil {
+		return nil, errors.New("history id must be a number")
+	}
+	return ReadHistoryEntry(cfgPath, id)
+}
+
+func HistoryShow(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		entry, err := historyEntryArg(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(entry)
+		if err != nil {
+			return errors.New("failed to generate command output")
+		}
+		fmt.Print(string(out))
+		return nil
+	}
+}
+
+func HistoryReplay(cfgPath string, httpClient http.Client) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		entry, err := historyEntryArg(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		if entry.Method == "GRPC" {
+			return errors.New("grpc calls cannot be replayed, call " + entry.Request + " again instead")
+		}
+		req, err := http.NewRequest(entry.Method, entry.URL, bytes.NewBuffer([]byte(entry.RequestBody)))
+		if err != nil {
+			return errors.New("failed to create web request")
+		}
+		// query parameters whose values were not saved are left out as well
+		if query := req.URL.Query(); len(query) > 0 {
+			for k, values := range query {
+				redacted := true
+				for _, v := range values {
+					redacted = redacted && v == redactedValue
+				}
+				if redacted {
+					query.Del(k)
+				}
+			}
+			req.URL.RawQuery = query.Encode()
+		}
+		for _, header := range entry.RequestHeaders {
+			// credentials were not saved
+			if header.Value == redactedValue && redactedHeaders[http.CanonicalHeaderKey(header.Name)] {
+				continue
+			}
+			req.Header.Add(header.Name, header.Value)
+		}
+		// use the app's TLS and proxy settings if it still exists
+		appinfo := new(AppInfo)
+		if contents, err := os.ReadFile(AppInfoFilePath(cfgPath, entry.App)); err == nil {
+			yaml.Unmarshal(contents, appinfo)
+		}
+		httpClient.Transport, err = newTransport(cfgPath, ctx, httpClient.Transport, appinfo)
+		if err != nil {
+			return err
+		}
+		t1 := time.Now()
+		resp, err := httpClient.Do(req)
+		t2 := time.Now()
+		if err != nil {
+			recordHistory(cfgPath, newHistoryEntry(entry.App, entry.Request, entry.Vars, req, nil, nil, t2.Sub(t1), err))
+			return errors.New("failed to send web request")
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return errors.New("failed to read response body")
+		}
+		recordHistory(cfgPath, newHistoryEntry(entry.App, entry.Request, entry.Vars, req, resp, respBody, t2.Sub(t1), nil))
+		if err := checkPrintable(respBody, resp.Header.Get("Content-Type")); err != nil {
+			return err
+		}
+		printBody(ctx, os.Stdout, respBody, resp.Header.Get("Content-Type"))
+		return nil
+	}
+}
+
+// Prints the differences between a response and its snapshot. Returns an
+// error if there are any.
+func compareSnapshot(w io.Writer, snapshot *Snapshot, resp *http.Response, body []byte, ignore []string) error {
+	differences, err := diffResponses(snapshot.StatusCode, resp.StatusCode, snapshot.Body, string(body), append(snapshot.Ignore, ignore...))
+	if err != nil {
+		return err
+	}
+	if len(differences) == 0 {
+		fmt.Fprintln(w, "response matches snapshot")
+		return nil
+	}
+	fmt.Fprintln(w, strings.Join(differences, "\n"))
+	return errors.New("response does not match snapshot")
+}
+
+func HistoryDiff(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return errors.New("expected exactly two arguments")
+		}
+		var entries [2]*HistoryEntry
+		for i := range entries {
+			id, err := strconv.Atoi(ctx.Args().Get(i))
+			if err != nil {
+				return errors.New("history id must be a number")
+			}
+			entries[i], err = ReadHistoryEntry(cfgPath, id)
+			if err != nil {
+				return err
+			}
+		}
+		differences, err := diffResponses(entries[0].StatusCode, entries[1].StatusCode, entries[0].ResponseBody, entries[1].ResponseBody, ctx.StringSlice("ignore"))
+		if err != nil {
+			return err
+		}
+		if len(differences) == 0 {
+			fmt.Println("responses are identical")
+			return nil
+		}
+		fmt.Println(strings.Join(differences, "\n"))
+		return errors.New("responses differ")
+	}
+}
+
+func HistoryClear(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		ids, err := historyIDs(cfgPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("You are about to delete %d call(s) from the history.\nThis action cannot be undone.\n", len(ids))
+		if ctx.Bool("confirm") || ConfirmPrompt() {
+			if err := os.RemoveAll(HistoryPath(cfgPath)); err != nil {
+				return err
+			}
+			fmt.Print("history has been cleared")
+			return nil
+		}
+		fmt.Println("clear aborted")
+		return nil
+	}
+}
+
+// Shows or changes the variables stored for an application
+func Variables(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 0 {
+			return errors.New("vars does not take any arguments")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		vars, err := ReadVariables(cfgPath, app)
+		if err != nil {
+			return err
+		}
+		if !ctx.IsSet("set") && !ctx.IsSet("unset") {
+			if len(vars) == 0 {
+				return nil
+			}
+			out, err := yaml.Marshal(vars)
+			if err != nil {
+				return errors.New("failed to generate command output")
+			}
+			fmt.Print(string(out))
+			return nil
+		}
+		set, err := parseVars(ctx.StringSlice("set"))
+		if err != nil {
+			return err
+		}
+		for k, v := range set {
+			vars[k] = v
+		}
+		for _, k := range ctx.StringSlice("unset") {
+			delete(vars, k)
+		}
+		return WriteVariables(cfgPath, app, vars)
+	}
+}
+
+// Serves the example responses of an application's requests until interrupted.
+// Requests without an example are answered with their snapshot.
+func Mock(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 1 {
+			return errors.New("mock takes at most one argument")
+		}
+		app := ctx.Args().Get(0)
+		if app == "" {
+			app = currentApp(cfgPath)
+		}
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		contents, err := os.ReadFile(AppInfoFilePath(cfgPath, app))
+		if err != nil {
+			return errors.New("failed to retrieve app information")
+		}
+		appinfo := new(AppInfo)
+		if err := yaml.Unmarshal(contents, appinfo); err != nil {
+			return errors.New("appinfo file is malformed or corrupted")
+		}
+		if err := validDuration("latency", ctx.String("latency")); err != nil {
+			return err
+		}
+		latency, _ := time.ParseDuration(ctx.String("latency"))
+		reqs, err := readRequestFiles(cfgPath, app)
+		if err != nil {
+			return err
+		}
+		handler, err := newMockServer(cfgPath, app, appinfo, reqs, latency)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", "localhost:"+strconv.Itoa(ctx.Int("port")))
+		if err != nil {
+			return errors.New("failed to listen on port " + strconv.Itoa(ctx.Int("port")))
+		}
+		fmt.Printf("Mocking %s on http://%s (%d routes)\n", app, listener.Addr(), len(handler.routes))
+		return serveUntilInterrupted(ctx, listener, handler)
+	}
+}
+
+// Forwards traffic to a target, by default the app's host, and saves every
+// distinct request passing through as a request of the app
+func Record(cfgPath string) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 0 {
+			return errors.New("record does not take any arguments")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		contents, err := os.ReadFile(AppInfoFilePath(cfgPath, app))
+		if err != nil {
+			return errors.New("failed to retrieve app information")
+		}
+		appinfo := new(AppInfo)
+		if err := yaml.Unmarshal(contents, appinfo); err != nil {
+			return errors.New("appinfo file is malformed or corrupted")
+		}
+		target := appinfo.Host
+		if ctx.String("target") != "" {
+			target = ctx.String("target")
+		}
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("target must be an http or https URL")
+		}
+		reqs, err := readRequestFiles(cfgPath, app)
+		if err != nil {
+			return err
+		}
+		listener, err := net.Listen("tcp", ctx.String("listen"))
+		if err != nil {
+			return errors.New("failed to listen on " + ctx.String("listen"))
+		}
+		fmt.Printf("Recording %s on http://%s, forwarding to %s\n", app, listener.Addr(), u)
+		return serveUntilInterrupted(ctx, listener, newRecorder(cfgPath, app, u, reqs))
+	}
+}
+
+// Sends a request many times, concurrently, and reports throughput, errors and
+// latency
+func Bench(cfgPath string, httpClient http.Client) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 {
+			return errors.New("expected exactly one argument")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		if !valid(app) {
+			return errors.New("application name is invalid")
+		}
+		if !validRequest(ctx.Args().Get(0)) {
+			return errors.New("request name is invalid")
+		}
+		appinfo, err := readAppInfo(cfgPath, app)
+		if err != nil {
+			return err
+		}
+		reqinfo, err := readRequestInfo(cfgPath, app, ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if reqinfo.Kind == "websocket" || reqinfo.Kind == "grpc" {
+			return errors.New("only http and graphql requests can be benchmarked")
+		}
+		opts := benchOptions{concurrency: ctx.Int("concurrency"), requests: ctx.Int("requests")}
+		if opts.concurrency < 1 {
+			return errors.New("concurrency must be at least 1")
+		}
+		if ctx.IsSet("requests") && opts.requests < 1 {
+			return errors.New("requests must be at least 1")
+		}
+		if err := validDuration("duration", ctx.String("duration")); err != nil {
+			return err
+		}
+		opts.duration, _ = time.ParseDuration(ctx.String("duration"))
+		if !ctx.IsSet("requests") && opts.duration == 0 {
+			opts.requests = defaultBenchRequests
+		}
+		if ctx.String("rate") != "" {
+			if opts.interval, err = parseRate(ctx.String("rate")); err != nil {
+				return err
+			}
+		}
+		flagVars, err := parseVars(ctx.StringSlice("var"))
+		if err != nil {
+			return err
+		}
+		groups, err := requestGroups(cfgPath, app, ctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		vars, err := groupVariables(cfgPath, app, groups)
+		if err != nil {
+			return err
+		}
+		for k, v := range flagVars {
+			vars[k] = v
+		}
+		reqinfo, err = resolveRequest(appinfo, groups, reqinfo, vars)
+		if err != nil {
+			return err
+		}
+		newReq := func() (*http.Request, error) {
+			if reqinfo.Kind == "graphql" {
+				return newGraphQLRequest(appinfo, reqinfo, vars)
+			}
+			return newRequest(appinfo, reqinfo, vars)
+		}
+		// fail before starting if the request cannot be built
+		if _, err := newReq(); err != nil {
+			return err
+		}
+		httpClient.Transport, err = newTransport(cfgPath, ctx, httpClient.Transport, appinfo)
+		if err != nil {
+			return err
+		}
+		if tr, ok := httpClient.Transport.(*http.Transport); ok {
+			// keep a connection open for every worker
+			tr.MaxIdleConnsPerHost = opts.concurrency
+		}
+		httpClient.Timeout, err = callTimeout(ctx, reqinfo)
+		if err != nil {
+			return err
+		}
+		c, stop := signal.NotifyContext(ctx.Context, os.Interrupt)
+		defer stop()
+		results, elapsed, err := runBench(c, &httpClient, newReq, opts)
+		if err != nil {
+			return err
+		}
+		printBenchReport(os.Stdout, results, elapsed)
+		return nil
+	}
+}
+
+// Creates a request for every query and mutation of a GraphQL server, using
+// introspection. Existing requests are left alone.
+func ImportGraphQLSchema(cfgPath string, httpClient http.Client) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() > 0 {
+			return errors.New("import graphql-schema does not take any arguments")
+		}
+		app, err := getApp(cfgPath, ctx)
+		if err != nil {
+			return err
+		}
+		if !valid(app) || !appExists(cfgPath, app) {
+			return errors.New("application does not exist")
+		}
+		contents, err := os.ReadFile(AppInfoFilePath(cfgPath, app))
+		if err != nil {
+			return errors.New("failed to retrieve app information")
+		}
+		appinfo := new(AppInfo)
+		if err := yaml.Unmarshal(contents, appinfo); err != nil {
+			return errors.New("appinfo file is malformed or corrupted")
+		}
+		headers, err := parseHeaders(ctx.StringSlice("header"))
+		if err != nil {
+			return err
+		}
+		endpoint, err := buildURL(appinfo.Host, ctx.String("path"), nil)
+		if err != nil {
+			return err
+		}
+		httpClient.Transport, err = newTransport(cfgPath, ctx, httpClient.Transport, appinfo)
+		if err != nil {
+			return err
+		}
+		schema, err := introspect(httpClient, endpoint.String(), headers)
+		if err != nil {
+			return err
+		}
+		for _, reqinfo := range graphqlRequests(schema, ctx.String("path")) {
+			if !valid(reqinfo.Name) {
+				fmt.Printf("Skipped %s, the name is not a valid request name\n", reqinfo.Name)
+				continue
+			}
+			if _, err := os.Stat(ReqPath(cfgPath, app, reqinfo.Name)); err == nil {
+				fmt.Printf("Skipped %s, the request already exists\n", reqinfo.Name)
+				continue
+			}
+			reqinfo.Headers = headers
+			if err := WriteRequestFiles(cfgPath, app, reqinfo); err != nil {
+				return err
 			}
-			fmt.Print(string(o))
+			fmt.Printf("Created request %s\n", reqinfo.Name)
 		}
 		return nil
 	}
@@ -1,18 +1,33 @@
 package action_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gabehf/sp9rk/action"
 	"github.com/gabehf/sp9rk/app"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"gopkg.in/yaml.v3"
 )
 
@@ -43,6 +58,16 @@ func TestWriteFiles(t *testing.T) {
 	assert.NoError(t, err, "FIX FIRST: failed to write request file")
 	_, err = os.Stat(path.Join(p, "MyReq.yml"))
 	assert.NoError(t, err, "failed to stat request file")
+	// files that may hold credentials are only readable by their owner, even
+	// when an earlier version saved them with wider permissions
+	os.Chmod(path.Join(p, ".appinfo"), 0755)
+	assert.NoError(t, action.WriteAppFiles(cfgPath, &action.AppInfo{Name: "TestApp", Host: "localhost"}))
+	assert.NoError(t, action.WriteConfig(cfgPath, &action.Config{HistoryLimit: 10}))
+	for _, f := range []string{path.Join(p, ".appinfo"), path.Join(p, "MyReq.yml"), action.ConfigFilePath(cfgPath)} {
+		if info, err := os.Stat(f); assert.NoError(t, err) {
+			assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), f)
+		}
+	}
 	os.RemoveAll("TestActionWriteFiles")
 }
 
@@ -135,8 +160,8 @@ func TestActionCreateRequest(t *testing.T) {
 	assert.EqualValues(t, reqinfo.Method, "POST")
 	assert.EqualValues(t, reqinfo.Path, "/path")
 	assert.EqualValues(t, reqinfo.Body, "request body")
-	assert.EqualValues(t, reqinfo.Headers[0], "X-Header-One: 1")
-	assert.EqualValues(t, reqinfo.Headers[1], "X-Header-Two: 2")
+	assert.EqualValues(t, reqinfo.Headers[0], action.Header{Name: "X-Header-One", Value: "1"})
+	assert.EqualValues(t, reqinfo.Headers[1], action.Header{Name: "X-Header-Two", Value: "2"})
 	// cleanup
 	os.RemoveAll("TestActionCreateRequest")
 }
@@ -264,7 +289,7 @@ func TestActionEditRequest(t *testing.T) {
 		Description: "hello",
 		Body:        "request body",
 		Method:      "POST",
-		Headers:     []string{"Header: One"},
+		Headers:     []action.Header{{Name: "Header", Value: "One"}},
 	})
 	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
 	// tests
@@ -289,7 +314,7 @@ func TestActionEditRequest(t *testing.T) {
 	assert.EqualValues(t, "new description", reqinfo.Description, "description should be updated")
 	assert.EqualValues(t, "POST", reqinfo.Method, "method should be updated")
 	assert.EqualValues(t, "new body", reqinfo.Body, "body should be updated")
-	assert.EqualValues(t, "X-Header: ABC", reqinfo.Headers[0], "header(s) should be updated")
+	assert.EqualValues(t, "X-Header: ABC", reqinfo.Headers[0].String(), "header(s) should be updated")
 	assert.EqualValues(t, "/new-path", reqinfo.Path, "path should be updated")
 	assert.Error(t, RunWithArgs(app, "edit", "req"), "edit req should fail with no args")
 	assert.NoError(t, RunWithArgs(app, "edit", "req", "ReqTwo"), "edit req should NOT fail with no flags")
@@ -297,13 +322,165 @@ func TestActionEditRequest(t *testing.T) {
 	contents, _ = os.ReadFile(path.Join(action.AppPath(cfgPath, "TestApp"), "ReqTwo.yml"))
 	reqinfo = new(action.RequestInfo)
 	yaml.Unmarshal(contents, reqinfo)
-	assert.EqualValues(t, "Header: One", reqinfo.Headers[0], "header(s) should NOT be overwritten when not updated")
+	assert.EqualValues(t, "Header: One", reqinfo.Headers[0].String(), "header(s) should NOT be overwritten when not updated")
 	assert.EqualValues(t, "/path", reqinfo.Path, "path should NOT be overwritten when not updated")
 	assert.EqualValues(t, "request body", reqinfo.Body, "header(s) should NOT be overwritten when not updated")
 	assert.EqualValues(t, "POST", reqinfo.Method, "method should NOT be overwritten when not updated")
 	os.RemoveAll("TestActionEditRequest")
 }
 
+func TestActionEditRequestHeaders(t *testing.T) {
+	cfgPath := path.Join("TestActionEditRequestHeaders", ".sp9rk", "tests")
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+	})
+	// request files from older versions store headers as strings
+	os.WriteFile(action.ReqPath(cfgPath, "TestApp", "Req"), []byte(`version: "1"
+name: Req
+method: GET
+path: /
+headers:
+    - 'Accept: application/json'
+    - 'X-Trace: abc'
+`), 0700)
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+	readHeaders := func() []action.Header {
+		contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "Req"))
+		reqinfo := new(action.RequestInfo)
+		assert.NoError(t, yaml.Unmarshal(contents, reqinfo), "request file should be correctly formed")
+		return reqinfo.Headers
+	}
+	assert.Equal(t, []action.Header{
+		{Name: "Accept", Value: "application/json"},
+		{Name: "X-Trace", Value: "abc"},
+	}, readHeaders(), "legacy headers should be parsed")
+
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--add-header", "x-custom:  one ", "Req"))
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--add-header", "X-Custom: two", "--add-header", "X-Custom: one", "Req"))
+	assert.Equal(t, []action.Header{
+		{Name: "Accept", Value: "application/json"},
+		{Name: "X-Trace", Value: "abc"},
+		{Name: "X-Custom", Value: "one"},
+		{Name: "X-Custom", Value: "two"},
+	}, readHeaders(), "added headers should be normalized and exact duplicates skipped")
+
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--set-header", "X-Custom: three", "--set-header", "Accept: text/plain", "Req"))
+	assert.Equal(t, []action.Header{
+		{Name: "Accept", Value: "text/plain"},
+		{Name: "X-Trace", Value: "abc"},
+		{Name: "X-Custom", Value: "three"},
+	}, readHeaders(), "set should replace every header with the same name")
+
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--remove-header", "x-trace", "Req"))
+	assert.Equal(t, []action.Header{
+		{Name: "Accept", Value: "text/plain"},
+		{Name: "X-Custom", Value: "three"},
+	}, readHeaders(), "remove should delete the header")
+
+	assert.Error(t, RunWithArgs(app, "edit", "req", "--add-header", "no separator", "Req"), "malformed headers should be rejected")
+	assert.Error(t, RunWithArgs(app, "edit", "req", "--set-header", "Bad Name: x", "Req"), "invalid header names should be rejected")
+	assert.Len(t, readHeaders(), 2, "failed edits should not change the request")
+
+	// saved names are matched without regard to case
+	os.WriteFile(action.ReqPath(cfgPath, "TestApp", "Req"), []byte(`version: "1"
+name: Req
+method: GET
+path: /
+headers:
+    - name: content-type
+      value: text/plain
+    - name: x-trace
+      value: abc
+`), 0700)
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--set-header", "Content-Type: application/json", "--remove-header", "X-Trace", "Req"))
+	assert.Equal(t, []action.Header{
+		{Name: "Content-Type", Value: "application/json"},
+	}, readHeaders(), "set and remove should match headers saved in another case")
+	os.RemoveAll("TestActionEditRequestHeaders")
+}
+
+func TestActionEditRequestInteractive(t *testing.T) {
+	cfgPath := path.Join("TestActionEditRequestInteractive", ".sp9rk", "tests")
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:        "Req",
+		Method:      "GET",
+		Path:        "/path",
+		Description: "hello",
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	// the first save adds an unknown field, the second fixes it once the error is shown
+	editor, _ := filepath.Abs(path.Join("TestActionEditRequestInteractive", "editor.sh"))
+	os.WriteFile(editor, []byte(`#!/bin/sh
+if grep -q ERROR "$1"; then
+	sed -i -e 's/^bogus: .*$//' -e 's/^description: .*$/description: ""/' "$1"
+else
+	echo "bogus: field" >> "$1"
+fi
+`), 0700)
+	t.Setenv("SP9RK_EDITOR", editor)
+	app := app.New(cfgPath, http.Client{})
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--interactive", "Req"), "interactive edit should succeed once the request is valid")
+	contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "Req"))
+	reqinfo := new(action.RequestInfo)
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, "", reqinfo.Description, "interactive edit should be able to clear fields")
+	assert.Equal(t, "/path", reqinfo.Path, "path should NOT be changed")
+
+	// saving an empty file aborts the edit
+	os.WriteFile(editor, []byte("#!/bin/sh\n: > \"$1\"\n"), 0700)
+	output, err := captureOutput(RunWithArgs, app, "edit", "req", "-i", "-d", "new description", "Req")
+	assert.NoError(t, err, "aborted edit should not fail")
+	assert.Equal(t, "edit aborted\n", output)
+	after, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "Req"))
+	assert.Equal(t, string(contents), string(after), "aborted edit should not change the request")
+
+	// lines starting with '#' in a body are not comments
+	body := "# Title\n\n  # indented\necho '#!/bin/sh'\n{\"tag\": \"#1\"}\n"
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "-b", body, "Req"))
+	os.WriteFile(editor, []byte("#!/bin/sh\nsed -i 's/^description: .*$/description: edited/' \"$1\"\n"), 0700)
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "-i", "Req"))
+	contents, _ = os.ReadFile(action.ReqPath(cfgPath, "TestApp", "Req"))
+	reqinfo = new(action.RequestInfo)
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, "edited", reqinfo.Description)
+	assert.Contains(t, string(contents), "body: |", "the body should be saved as a block scalar")
+	assert.Equal(t, body, reqinfo.Body, "interactive edit should keep '#' lines of the body")
+
+	// invalid values are reported in the file, here before aborting the edit
+	inject, _ := filepath.Abs(path.Join("TestActionEditRequestInteractive", "inject.yml"))
+	reported, _ := filepath.Abs(path.Join("TestActionEditRequestInteractive", "error.txt"))
+	os.WriteFile(editor, []byte(`#!/bin/sh
+if grep -q ERROR "$1"; then
+	grep '^#' "$1" > `+reported+`
+	: > "$1"
+else
+	sed -i -e '/^method: /d' -e '/^headers: \[\]$/d' "$1"
+	cat `+inject+` >> "$1"
+fi
+`), 0700)
+	for edit, expected := range map[string]string{
+		"method: GET /":                                           "method GET / is invalid",
+		"method: GET\nquery:\n  \"\": [x]":                        "query parameters must have a name",
+		"method: GET\nheaders:\n  - name: Bad Name\n    value: x": "invalid header name Bad Name",
+		"method: GET\nhooks:\n  pre_request: \" \"":               "pre_request hook must not be blank",
+		"method: GET\nkind: grpc\nhooks:\n  pre_request: echo":    "hooks only apply to http and graphql requests",
+		"method: GET\nkind: graphql\nvariables: '{\"id\": '":      "graphql variables are not valid JSON",
+	} {
+		os.WriteFile(inject, []byte(edit+"\n"), 0600)
+		os.Remove(reported)
+		assert.NoError(t, RunWithArgs(app, "edit", "req", "-i", "Req"))
+		contents, _ = os.ReadFile(reported)
+		assert.Contains(t, string(contents), expected, edit)
+	}
+	after, _ = os.ReadFile(action.ReqPath(cfgPath, "TestApp", "Req"))
+	assert.Contains(t, string(after), "method: GET\n", "invalid edits should not be saved")
+	os.RemoveAll("TestActionEditRequestInteractive")
+}
+
 func TestActionListApps(t *testing.T) {
 	cfgPath := path.Join("TestActionListApps", ".sp9rk", "tests")
 	// simulate apps being created
@@ -432,8 +609,8 @@ func TestActionRequestInfo(t *testing.T) {
 		Description: "my request",
 		Path:        "/path",
 		Body:        "request body",
-		Headers: []string{
-			"X-API-Key: ABC123",
+		Headers: []action.Header{
+			{Name: "X-API-Key", Value: "ABC123"},
 		},
 	})
 	// tests
@@ -442,7 +619,8 @@ func TestActionRequestInfo(t *testing.T) {
 	method: GET
 	path: /path
 	headers:
-	    - 'X-API-Key: ABC123'
+	    - name: X-API-Key
+	      value: ABC123
 	body: request body
 `
 	app := app.New(cfgPath, http.Client{})
@@ -488,8 +666,8 @@ func TestActionCall(t *testing.T) {
 		Description: "my request",
 		Path:        "/path",
 		Body:        "request body",
-		Headers: []string{
-			"X-API-Key: ABC123",
+		Headers: []action.Header{
+			{Name: "X-API-Key", Value: "ABC123"},
 		},
 	})
 	// test basic functionality
@@ -543,8 +721,8 @@ func TestActionCallFail(t *testing.T) {
 		Description: "my request",
 		Path:        "/path",
 		Body:        "request body",
-		Headers: []string{
-			"X-API-Key: ABC123",
+		Headers: []action.Header{
+			{Name: "X-API-Key", Value: "ABC123"},
 		},
 	})
 	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
@@ -555,6 +733,1793 @@ func TestActionCallFail(t *testing.T) {
 	os.RemoveAll("TestActionCallFail")
 }
 
+func TestActionCallTLS(t *testing.T) {
+	cfgPath := path.Join("TestActionCallTLS", ".sp9rk", "tests")
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`Hello, TLS!`))
+	}))
+	defer server.Close()
+
+	app := app.New(cfgPath, http.Client{})
+	caPath := path.Join("TestActionCallTLS", "ca.pem")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0700)
+	assert.Error(t, RunWithArgs(app, "create", "app", "-u", server.URL, "--ca-cert", "missing.pem", "TestApp"), "create app should fail with missing CA bundle")
+	assert.Error(t, RunWithArgs(app, "create", "app", "-u", server.URL, "--tls-min-version", "2.0", "TestApp"), "create app should fail with unknown TLS version")
+	assert.NoError(t, RunWithArgs(app, "create", "app", "-u", server.URL, "TestApp"))
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:   "MyReq",
+		Method: "GET",
+		Path:   "/",
+	})
+	assert.Error(t, RunWithArgs(app, "call", "-a", "TestApp", "MyReq"), "call should fail with an unknown CA")
+	output, err := captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "--insecure", "MyReq")
+	assert.NoError(t, err, "call should succeed with insecure flag")
+	assert.Equal(t, "Hello, TLS!\n", output)
+
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--ca-cert", caPath, "--tls-min-version", "1.2", "TestApp"))
+	contents, _ := os.ReadFile(action.AppInfoFilePath(cfgPath, "TestApp"))
+	appinfo := new(action.AppInfo)
+	yaml.Unmarshal(contents, appinfo)
+	assert.True(t, path.IsAbs(appinfo.TLS.CACert), "CA bundle path should be stored as an absolute path")
+	assert.Equal(t, server.URL, appinfo.Host, "host should NOT be overwritten when not updated")
+	output, err = captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "MyReq")
+	assert.NoError(t, err, "call should succeed with the app's CA bundle")
+	assert.Equal(t, "Hello, TLS!\n", output)
+
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--server-name", "wrong.example", "TestApp"))
+	assert.Error(t, RunWithArgs(app, "call", "-a", "TestApp", "MyReq"), "call should fail when the server name does not match")
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--server-name", "example.com", "TestApp"))
+	assert.NoError(t, RunWithArgs(app, "call", "-a", "TestApp", "MyReq"), "call should succeed with a matching server name")
+	os.RemoveAll("TestActionCallTLS")
+}
+
+func TestActionCallProxy(t *testing.T) {
+	cfgPath := path.Join("TestActionCallProxy", ".sp9rk", "tests")
+	// a forward proxy that echoes the requested URL and credentials
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("proxied " + r.URL.String() + " " + r.Header.Get("Proxy-Authorization")))
+	}))
+	defer proxy.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("other " + r.URL.String()))
+	}))
+	defer other.Close()
+
+	app := app.New(cfgPath, http.Client{})
+	assert.Error(t, RunWithArgs(app, "create", "app", "-u", "http://backend.test", "--proxy", "ftp://proxy", "TestApp"), "create app should fail with unsupported proxy scheme")
+	assert.NoError(t, RunWithArgs(app, "create", "app", "-u", "http://backend.test", "--proxy", proxy.URL, "--proxy-user", "user:pass", "TestApp"))
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:   "MyReq",
+		Method: "GET",
+		Path:   "/path",
+	})
+	output, err := captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "MyReq")
+	assert.NoError(t, err, "call should succeed through the app's proxy")
+	assert.Equal(t, "proxied http://backend.test/path Basic dXNlcjpwYXNz\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "--proxy", other.URL, "MyReq")
+	assert.NoError(t, err, "call should succeed with proxy override")
+	assert.Equal(t, "other http://backend.test/path\n", output)
+
+	// global proxy is used when the app does not have one
+	assert.NoError(t, RunWithArgs(app, "create", "app", "-u", "http://backend.test", "TestAppTwo"))
+	action.WriteRequestFiles(cfgPath, "TestAppTwo", &action.RequestInfo{
+		Name:   "MyReq",
+		Method: "GET",
+		Path:   "/path",
+	})
+	assert.NoError(t, RunWithArgs(app, "config", "--proxy", other.URL))
+	output, err = captureOutput(RunWithArgs, app, "call", "-a", "TestAppTwo", "MyReq")
+	assert.NoError(t, err, "call should succeed through the global proxy")
+	assert.Equal(t, "other http://backend.test/path\n", output)
+	cfg, err := action.ReadConfig(cfgPath)
+	assert.NoError(t, err)
+	assert.Equal(t, other.URL, cfg.Proxy.URL, "global proxy should be saved")
+
+	// hosts in the no proxy list are dialed directly
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "-u", other.URL, "--no-proxy", "127.0.0.0/8", "TestAppTwo"))
+	output, err = captureOutput(RunWithArgs, app, "call", "-a", "TestAppTwo", "MyReq")
+	assert.NoError(t, err, "call should succeed without proxy")
+	assert.Equal(t, "other /path\n", output)
+
+	// the no proxy list may be given comma separated
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--no-proxy", "localhost, 127.0.0.0/8", "TestAppTwo"))
+	contents, _ := os.ReadFile(action.AppInfoFilePath(cfgPath, "TestAppTwo"))
+	appinfo := new(action.AppInfo)
+	yaml.Unmarshal(contents, appinfo)
+	assert.Equal(t, []string{"localhost", "127.0.0.0/8"}, appinfo.Proxy.NoProxy)
+	output, err = captureOutput(RunWithArgs, app, "call", "-a", "TestAppTwo", "MyReq")
+	assert.NoError(t, err, "call should succeed without proxy")
+	assert.Equal(t, "other /path\n", output)
+	os.RemoveAll("TestActionCallProxy")
+}
+
+func TestActionCallRetries(t *testing.T) {
+	cfgPath := path.Join("TestActionCallRetries", ".sp9rk", "tests")
+	// fail the first two attempts of every three
+	var hits, busy atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		if r.URL.Path == "/busy" {
+			busy.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if hits.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`Hello, World!`))
+	}))
+	defer server.Close()
+
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+	assert.Error(t, RunWithArgs(app, "create", "req", "--retry-on", "teapot", "MyReq"), "create req should fail with unknown retry condition")
+	assert.Error(t, RunWithArgs(app, "create", "req", "--timeout", "soon", "MyReq"), "create req should fail with invalid timeout")
+	assert.NoError(t, RunWithArgs(app, "create", "req", "--retries", "2", "--retry-on", "503,conn-error", "--retry-delay", "1ms", "MyReq"))
+	contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "MyReq"))
+	reqinfo := new(action.RequestInfo)
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, 2, reqinfo.Retries, "retries should be saved")
+	assert.Equal(t, []string{"503", "conn-error"}, reqinfo.RetryOn, "retry conditions should be saved")
+
+	output, err := captureOutput(RunWithArgs, app, "call", "--verbose", "MyReq")
+	assert.NoError(t, err, "call should succeed after retrying")
+	respData := new(action.VerboseCallResponse)
+	assert.NoError(t, yaml.Unmarshal([]byte(output), respData), "output should be valid yaml")
+	assert.Equal(t, "200 OK", respData.Status)
+	if assert.Len(t, respData.Attempts, 3, "verbose output should report every attempt") {
+		assert.Equal(t, "503 Service Unavailable", respData.Attempts[0].Status)
+		assert.Equal(t, 3, respData.Attempts[2].Attempt)
+	}
+	// a delay of 0 retries straight away, and retries of any method are reported
+	assert.NoError(t, RunWithArgs(app, "create", "req", "-X", "POST", "--retries", "2", "--retry-on", "503", "--retry-delay", "0s", "PostReq"))
+	start := time.Now()
+	output, err = captureOutput(RunWithArgs, app, "call", "--verbose", "PostReq")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second, "a retry delay of 0 should not wait")
+	respData = new(action.VerboseCallResponse)
+	assert.NoError(t, yaml.Unmarshal([]byte(output), respData), "output should be valid yaml")
+	assert.Equal(t, "POST "+server.URL+"/", respData.Request)
+	assert.Len(t, respData.Attempts, 3, "verbose output should report every attempt of a POST")
+	output, err = captureOutput(RunWithArgs, app, "call", "--retries", "0", "MyReq")
+	assert.NoError(t, err, "call should not fail on 503 without --fail")
+	assert.Equal(t, "\n", output, "call should not retry when retries are overridden")
+
+	// methods that are not idempotent are only retried with an explicit --retry-on
+	assert.NoError(t, RunWithArgs(app, "create", "req", "-X", "POST", "-p", "/busy", "--retries", "2", "--retry-delay", "0s", "BusyPost"))
+	assert.NoError(t, RunWithArgs(app, "call", "BusyPost"))
+	assert.EqualValues(t, 1, busy.Load(), "a POST should not be retried on the default conditions")
+	assert.NoError(t, RunWithArgs(app, "create", "req", "-X", "POST", "-p", "/busy", "-H", "Idempotency-Key: abc", "--retries", "3", "--retry-delay", "0s", "KeyedPost"))
+	assert.NoError(t, RunWithArgs(app, "call", "KeyedPost"))
+	assert.EqualValues(t, 5, busy.Load(), "a POST with an Idempotency-Key should be retried")
+	busy.Store(0)
+	assert.NoError(t, RunWithArgs(app, "call", "--retry-on", "503", "BusyPost"))
+	assert.EqualValues(t, 3, busy.Load(), "a POST should be retried on the given conditions")
+
+	// timeouts
+	assert.NoError(t, RunWithArgs(app, "create", "req", "-p", "/busy", "--retries", "5", "--retry-delay", "10s", "--timeout", "300ms", "BusyReq"))
+	start = time.Now()
+	output, err = captureOutput(RunWithArgs, app, "call", "--verbose", "BusyReq")
+	assert.NoError(t, err, "the last response should be kept when the timeout leaves no time to retry")
+	assert.Less(t, time.Since(start), 2*time.Second, "waiting to retry should not outlast the timeout")
+	respData = new(action.VerboseCallResponse)
+	assert.NoError(t, yaml.Unmarshal([]byte(output), respData), "output should be valid yaml")
+	assert.Equal(t, "503 Service Unavailable", respData.Status)
+	assert.NoError(t, RunWithArgs(app, "create", "req", "-p", "/slow", "--timeout", "50ms", "SlowReq"))
+	assert.Error(t, RunWithArgs(app, "call", "SlowReq"), "call should fail when the timeout is exceeded")
+	assert.NoError(t, RunWithArgs(app, "call", "--timeout", "1s", "SlowReq"), "call should succeed with a longer timeout")
+	os.RemoveAll("TestActionCallRetries")
+}
+
+func TestActionCallQuery(t *testing.T) {
+	cfgPath := path.Join("TestActionCallQuery", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(r.URL.EscapedPath() + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL + "/api",
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+	assert.Error(t, RunWithArgs(app, "create", "req", "--query", "novalue", "MyReq"), "create req should fail with malformed query")
+	assert.NoError(t, RunWithArgs(app, "create", "req",
+		"-p", "/users/{{.user}}?legacy=1",
+		"-q", "tag=a",
+		"-q", "tag=b",
+		"-q", "q=hello world, again",
+		"-q", "by={{.user}}",
+		"MyReq",
+	))
+	contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "MyReq"))
+	reqinfo := new(action.RequestInfo)
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, []string{"a", "b"}, reqinfo.Query["tag"], "repeated query parameters should be saved")
+
+	assert.Error(t, RunWithArgs(app, "call", "MyReq"), "call should fail when a template variable is missing")
+	output, err := captureOutput(RunWithArgs, app, "call", "--var", "user=bob", "MyReq")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/users/bob?by=bob&legacy=1&q=hello+world%2C+again&tag=a&tag=b\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "user=bob", "-q", "tag=c", "-q", "extra=1", "MyReq")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/users/bob?by=bob&extra=1&legacy=1&q=hello+world%2C+again&tag=c\n", output, "call query parameters should replace saved ones")
+
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--remove-query", "tag", "-q", "by=alice", "MyReq"))
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "user=bob", "MyReq")
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/users/bob?by=alice&legacy=1&q=hello+world%2C+again\n", output)
+
+	// escaped characters in paths are sent as they were written
+	for _, tc := range []struct{ path, sent string }{
+		{"/search/100%25", "/api/search/100%25"},
+		{"/files/a%2Fb", "/api/files/a%2Fb"},
+		{"/with space", "/api/with%20space"},
+		{"/with%20space/", "/api/with%20space/"},
+	} {
+		assert.NoError(t, RunWithArgs(app, "edit", "req", "-p", tc.path, "--remove-query", "by", "--remove-query", "q", "MyReq"))
+		output, err = captureOutput(RunWithArgs, app, "call", "MyReq")
+		assert.NoError(t, err)
+		assert.Equal(t, tc.sent+"?\n", output, tc.path)
+	}
+	os.RemoveAll("TestActionCallQuery")
+}
+
+func TestActionHistory(t *testing.T) {
+	cfgPath := path.Join("TestActionHistory", ".sp9rk", "tests")
+	var hits atomic.Int32
+	var lastAuth, lastQuery atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/big" {
+			w.Write([]byte(strings.Repeat("a", 100<<10)))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Echo", r.Header.Get("X-Name"))
+		w.Header().Set("Set-Cookie", "session=s3cret")
+		lastAuth.Store(r.Header.Get("Authorization"))
+		lastQuery.Store(r.URL.RawQuery)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("Hello, " + string(body)))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:    "MyReq",
+		Method:  "POST",
+		Path:    "/greet",
+		Headers: []action.Header{{Name: "X-Name", Value: "{{.name}}"}, {Name: "Authorization", Value: "Bearer t0k"}, {Name: "Cookie", Value: "a=b"}},
+		Query:   action.Query{"api_key": {"k3y"}},
+		Body:    "{{.name}}",
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:   "BigReq",
+		Method: "GET",
+		Path:   "/big",
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "history", "list")
+	assert.NoError(t, err)
+	assert.Equal(t, "No calls in history\n", output)
+	assert.NoError(t, RunWithArgs(app, "call", "--var", "name=Alice", "MyReq"))
+	assert.NoError(t, discardOutput(RunWithArgs, app, "call", "BigReq"))
+	output, err = captureOutput(RunWithArgs, app, "history", "list")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if assert.Len(t, lines, 2, "history should list every call") {
+		assert.Contains(t, lines[0], "TestApp/MyReq\tPOST "+server.URL+"/greet?api_key=%5Bredacted%5D\t201 Created")
+		assert.True(t, strings.HasPrefix(lines[1], "2\t"))
+	}
+
+	output, err = captureOutput(RunWithArgs, app, "history", "show", "1")
+	assert.NoError(t, err)
+	entry := new(action.HistoryEntry)
+	assert.NoError(t, yaml.Unmarshal([]byte(output), entry), "show should output valid yaml")
+	assert.Equal(t, "[redacted]", entry.Vars["name"], "variable values should not be written to the history")
+	assert.Equal(t, server.URL+"/greet?api_key=%5Bredacted%5D", entry.URL, "query values should not be written to the history")
+	assert.Equal(t, "Alice", entry.RequestBody)
+	assert.Equal(t, 201, entry.StatusCode)
+	assert.Equal(t, "Hello, Alice", entry.ResponseBody)
+	assert.Contains(t, entry.ResponseHeaders, action.Header{Name: "X-Echo", Value: "Alice"})
+	assert.Contains(t, entry.RequestHeaders, action.Header{Name: "Authorization", Value: "[redacted]"})
+	assert.Contains(t, entry.RequestHeaders, action.Header{Name: "Cookie", Value: "[redacted]"})
+	assert.Contains(t, entry.ResponseHeaders, action.Header{Name: "Set-Cookie", Value: "[redacted]"})
+	contents, _ := os.ReadFile(path.Join(action.HistoryPath(cfgPath), "00000001.yml"))
+	assert.NotContains(t, string(contents), "t0k", "credentials should not be written to the history")
+	assert.NotContains(t, string(contents), "s3cret", "credentials should not be written to the history")
+	assert.NotContains(t, string(contents), "k3y", "credentials should not be written to the history")
+	if info, err := os.Stat(path.Join(action.HistoryPath(cfgPath), "00000001.yml")); assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+	big, err := action.ReadHistoryEntry(cfgPath, 2)
+	assert.NoError(t, err)
+	assert.True(t, big.Truncated, "large bodies should be truncated")
+	assert.Len(t, big.ResponseBody, 64<<10)
+	assert.Error(t, RunWithArgs(app, "history", "show", "42"), "show should fail with unknown id")
+
+	// replay sends the resolved request again, without needing the variables
+	output, err = captureOutput(RunWithArgs, app, "history", "replay", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, Alice\n", output)
+	assert.EqualValues(t, 3, hits.Load())
+	assert.Equal(t, "", lastAuth.Load(), "replay should not send redacted credentials")
+	assert.Equal(t, "", lastQuery.Load(), "replay should not send redacted query parameters")
+
+	// retention
+	assert.NoError(t, RunWithArgs(app, "config", "--history-limit", "2"))
+	assert.NoError(t, discardOutput(RunWithArgs, app, "call", "BigReq"))
+	entries, err := action.ReadHistory(cfgPath)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2, "history should be pruned to the limit") {
+		assert.Equal(t, 3, entries[0].ID)
+		assert.Equal(t, 4, entries[1].ID)
+	}
+	assert.NoError(t, RunWithArgs(app, "history", "clear", "--confirm"))
+	entries, _ = action.ReadHistory(cfgPath)
+	assert.Empty(t, entries, "history should be cleared")
+
+	// values are kept when the config says so
+	assert.NoError(t, RunWithArgs(app, "config", "--history-values"))
+	assert.NoError(t, RunWithArgs(app, "call", "--var", "name=Bob", "MyReq"))
+	entries, err = action.ReadHistory(cfgPath)
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, entries) {
+		last := entries[len(entries)-1]
+		assert.Equal(t, "Bob", last.Vars["name"])
+		assert.Equal(t, server.URL+"/greet?api_key=k3y", last.URL)
+	}
+	assert.NoError(t, RunWithArgs(app, "config", "--history-values=false"))
+	os.RemoveAll("TestActionHistory")
+}
+
+func TestActionCallSnapshot(t *testing.T) {
+	cfgPath := path.Join("TestActionCallSnapshot", ".sp9rk", "tests")
+	var hits atomic.Int32
+	name := "Alice"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := hits.Add(1)
+		if r.URL.Path == "/text" {
+			w.Write([]byte("line one\nline " + name + "\nline three"))
+			return
+		}
+		if r.URL.Path == "/big" {
+			for i := 0; i < 5000; i++ {
+				switch {
+				case name == "rewritten":
+					w.Write([]byte("new row " + strconv.Itoa(i) + "\n"))
+				case i == 2500:
+					w.Write([]byte("row " + name + "\n"))
+				default:
+					w.Write([]byte("row " + strconv.Itoa(i) + "\n"))
+				}
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"meta":{"time":` + strconv.Itoa(int(n)) + `},"items":[{"id":1,"name":"` + name + `","seen":` + strconv.Itoa(int(n)) + `}]}`))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "MyReq", Method: "GET", Path: "/json"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "TextReq", Method: "GET", Path: "/text"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "BigReq", Method: "GET", Path: "/big"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	assert.Error(t, RunWithArgs(app, "call", "--compare", "MyReq"), "compare should fail without a snapshot")
+	assert.Error(t, RunWithArgs(app, "call", "--snapshot", "--ignore", "meta", "MyReq"), "snapshot should fail with an invalid ignore path")
+	assert.NoError(t, RunWithArgs(app, "call", "--snapshot", "--ignore", ".meta.time", "MyReq"))
+	snapshot, err := action.ReadSnapshot(cfgPath, "TestApp", "MyReq")
+	assert.NoError(t, err, "snapshot should be saved")
+	assert.Equal(t, []string{".meta.time"}, snapshot.Ignore)
+
+	output, err := captureOutput(RunWithArgs, app, "call", "--compare", "MyReq")
+	assert.Error(t, err, "compare should fail when an unignored field changes")
+	assert.Equal(t, "~ .items[0].seen: 1 -> 2\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--compare", "--ignore", ".items[].seen", "MyReq")
+	assert.NoError(t, err, "compare should pass when only ignored fields change")
+	assert.Equal(t, "response matches snapshot\n", output)
+	name = "Bob"
+	output, err = captureOutput(RunWithArgs, app, "call", "--compare", "--ignore", ".items[].seen", "MyReq")
+	assert.Error(t, err)
+	assert.Equal(t, "~ .items[0].name: \"Alice\" -> \"Bob\"\n", output)
+
+	// non JSON bodies are compared line by line
+	name = "two"
+	assert.NoError(t, RunWithArgs(app, "call", "--snapshot", "TextReq"))
+	name = "2"
+	output, err = captureOutput(RunWithArgs, app, "call", "--compare", "TextReq")
+	assert.Error(t, err)
+	assert.Equal(t, "- line two\n+ line 2\n", output)
+
+	// large bodies only diff the lines that differ, or every line when too
+	// many of them do
+	assert.NoError(t, discardOutput(RunWithArgs, app, "call", "--snapshot", "BigReq"))
+	name = "changed"
+	output, err = captureOutput(RunWithArgs, app, "call", "--compare", "BigReq")
+	assert.Error(t, err)
+	assert.Equal(t, "- row 2\n+ row changed\n", output)
+	name = "rewritten"
+	output, err = captureOutput(RunWithArgs, app, "call", "--compare", "BigReq")
+	assert.Error(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if assert.Len(t, lines, 10000) {
+		assert.Equal(t, "- row 0", lines[0])
+		assert.Equal(t, "+ new row 0", lines[5000])
+	}
+
+	// responses in the history can be compared too
+	output, err = captureOutput(RunWithArgs, app, "history", "diff", "--ignore", ".meta", "1", "2")
+	assert.Error(t, err, "history diff should fail when responses differ")
+	assert.Equal(t, "~ .items[0].seen: 1 -> 2\n", output)
+	output, err = captureOutput(RunWithArgs, app, "history", "diff", "--ignore", ".meta", "--ignore", ".items[].seen", "1", "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "responses are identical\n", output)
+
+	assert.NoError(t, RunWithArgs(app, "delete", "req", "--confirm", "MyReq"))
+	_, err = action.ReadSnapshot(cfgPath, "TestApp", "MyReq")
+	assert.Error(t, err, "deleting a request should delete its snapshot")
+	os.RemoveAll("TestActionCallSnapshot")
+}
+
+func TestActionCallFormat(t *testing.T) {
+	cfgPath := path.Join("TestActionCallFormat", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"name":"Alice","tags":["a","b"],"admin":false}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<?xml version="1.0"?><users><user id="1"><name>Alice &amp; Bob</name></user></users>`))
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<!DOCTYPE html><html><body><p class="x">Hi<br>there</p></body></html>`))
+		case "/markup":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<div><pre>  a\n    b</pre><script>if (a && b) { go() }</script><p><b>x</b> y</p><textarea> t </textarea></div>"))
+		case "/broken":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":`))
+		}
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	for _, name := range []string{"json", "xml", "html", "markup", "broken"} {
+		action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: name, Method: "GET", Path: "/" + name})
+	}
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "call", "json")
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"name\": \"Alice\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ],\n  \"admin\": false\n}\n", output, "JSON should be indented and not colorized when stdout is not a terminal")
+	output, err = captureOutput(RunWithArgs, app, "call", "--raw", "json")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"Alice","tags":["a","b"],"admin":false}`+"\n", output, "raw should print the body as received")
+	output, err = captureOutput(RunWithArgs, app, "call", "xml")
+	assert.NoError(t, err)
+	assert.Equal(t, `<?xml version="1.0"?>
+<users>
+  <user id="1">
+    <name>Alice &amp; Bob</name>
+  </user>
+</users>
+`, output)
+	output, err = captureOutput(RunWithArgs, app, "call", "html")
+	assert.NoError(t, err)
+	assert.Equal(t, `<!DOCTYPE html>
+<html>
+  <body>
+    <p class="x">Hi<br>there</p>
+  </body>
+</html>
+`, output, "text mixed with elements should be kept as received")
+	output, err = captureOutput(RunWithArgs, app, "call", "markup")
+	assert.NoError(t, err)
+	assert.Equal(t, "<div>\n  <pre>  a\n    b</pre>\n  <script>if (a && b) { go() }</script>\n  <p><b>x</b> y</p>\n  <textarea> t </textarea>\n</div>\n", output, "whitespace and scripts should be kept as received")
+	output, err = captureOutput(RunWithArgs, app, "call", "broken")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":`+"\n", output, "bodies that do not parse should be printed as received")
+	os.RemoveAll("TestActionCallFormat")
+}
+
+func TestActionCallSelect(t *testing.T) {
+	cfgPath := path.Join("TestActionCallSelect", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token":"abc","items":[{"id":1},{"id":2}]}`))
+		case "/me":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"ok":true}`))
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(`<users><user id="1"><name>Alice</name></user><user id="2"><name>Bob</name></user></users>`))
+		}
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "xml", Method: "GET", Path: "/xml"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	_, err := captureOutput(RunWithArgs, app, "create", "req", "--path", "/login", "--capture", "token=.token", "--assert", "status == 200", "--assert", ".items[0].id == 1", "login")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "--path", "/me", "--header", "Authorization: Bearer {{.token}}", "--assert", ".ok", "me")
+	assert.NoError(t, err)
+	reqinfo := new(action.RequestInfo)
+	contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "login"))
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, []action.Assertion{{Select: "status", Op: "==", Value: "200"}, {Select: ".items[0].id", Op: "==", Value: "1"}}, reqinfo.Assert)
+	assert.Equal(t, map[string]string{"token": ".token"}, reqinfo.Capture)
+
+	output, err := captureOutput(RunWithArgs, app, "call", "--select", ".items[1].id", "login")
+	assert.NoError(t, err)
+	assert.Equal(t, "2\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--select", ".items[].id", "login")
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--select", ".token", "login")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc\n", output, "strings should be printed without quotes")
+	_, err = captureOutput(RunWithArgs, app, "call", "--select", ".missing", "login")
+	assert.EqualError(t, err, "no match for .missing")
+	output, err = captureOutput(RunWithArgs, app, "call", "--select", `//user[@id="2"]/name`, "xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bob\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--select", "/users/user/@id", "xml")
+	assert.NoError(t, err)
+	assert.Equal(t, "1\n2\n", output)
+
+	// the token captured by login is used by me
+	output, err = captureOutput(RunWithArgs, app, "vars")
+	assert.NoError(t, err)
+	assert.Equal(t, "token: abc\n", output)
+	_, err = captureOutput(RunWithArgs, app, "call", "me")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "vars", "--unset", "token")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "me")
+	assert.Error(t, err, "the token should no longer be set")
+	_, err = captureOutput(RunWithArgs, app, "call", "--var", "token=wrong", "me")
+	assert.EqualError(t, err, "1 of 1 assertion(s) failed")
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", ".items[].id ~= ^[0-9]+$", "--remove-capture", "token", "login")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "login")
+	assert.NoError(t, err)
+	output, _ = captureOutput(RunWithArgs, app, "vars")
+	assert.Equal(t, "", output, "removed captures should not be saved")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", ".items[].id != 2", "login")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "login")
+	assert.EqualError(t, err, "1 of 1 assertion(s) failed")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", ".items[", "login")
+	assert.EqualError(t, err, "invalid path .items[")
+
+	// hand edited assertions fail the call instead of crashing it
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:   "broken",
+		Method: "GET",
+		Path:   "/login",
+		Assert: []action.Assertion{{Select: ".token", Op: "~=", Value: "(["}, {Select: "status", Op: "<>", Value: "200"}},
+	})
+	_, err = captureOutput(RunWithArgs, app, "call", "broken")
+	assert.EqualError(t, err, "2 of 2 assertion(s) failed")
+	// only assertions that are added are checked, so the request can still be edited
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "-d", "still broken", "broken")
+	assert.NoError(t, err, "editing other fields should keep the broken assertions")
+	_, err = captureOutput(RunWithArgs, app, "call", "broken")
+	assert.EqualError(t, err, "2 of 2 assertion(s) failed")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", "/token ~= ([", "broken")
+	assert.EqualError(t, err, "invalid regular expression ([")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--clear-asserts", "broken")
+	assert.NoError(t, err, "clearing the assertions should fix the request")
+	_, err = captureOutput(RunWithArgs, app, "call", "broken")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", "status == 200", "broken")
+	assert.NoError(t, err)
+	os.RemoveAll("TestActionCallSelect")
+}
+
+func TestActionCallDownload(t *testing.T) {
+	cfgPath := path.Join("TestActionCallDownload", ".sp9rk", "tests")
+	data := make([]byte, 200<<10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "download", Method: "GET", Path: "/files/TestActionCallDownload.bin"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "missing", Method: "GET", Path: "/missing"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+	file := path.Join("TestActionCallDownload", "data.bin")
+
+	err := discardOutput(RunWithArgs, app, "call", "--output-file", file, "download")
+	assert.NoError(t, err)
+	saved, _ := os.ReadFile(file)
+	assert.Equal(t, data, saved)
+
+	// resume a partial download
+	os.WriteFile(file, data[:1000], 0644)
+	err = discardOutput(RunWithArgs, app, "call", "--continue", "-o", file, "download")
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes=1000-", ranges[len(ranges)-1])
+	saved, _ = os.ReadFile(file)
+	assert.Equal(t, data, saved)
+	err = discardOutput(RunWithArgs, app, "call", "--continue", "-o", file, "download")
+	assert.NoError(t, err, "resuming a complete download should succeed")
+	saved, _ = os.ReadFile(file)
+	assert.Equal(t, data, saved)
+
+	// a failed resume leaves the partial file alone
+	os.WriteFile(file, data[:1000], 0644)
+	err = discardOutput(RunWithArgs, app, "call", "--continue", "-o", file, "missing")
+	assert.EqualError(t, err, "server responded with 404 Not Found, "+file+" was not changed")
+	saved, _ = os.ReadFile(file)
+	assert.Equal(t, data[:1000], saved)
+
+	// so does an error page with --fail
+	os.WriteFile(file, data, 0644)
+	err = discardOutput(RunWithArgs, app, "call", "--fail", "-o", file, "missing")
+	assert.EqualError(t, err, "server responded with 404 Not Found, "+file+" was not changed")
+	saved, _ = os.ReadFile(file)
+	assert.Equal(t, data, saved, "--fail should not overwrite a download with an error page")
+
+	err = discardOutput(RunWithArgs, app, "call", "-O", "download")
+	assert.NoError(t, err)
+	saved, _ = os.ReadFile("TestActionCallDownload.bin")
+	assert.Equal(t, data, saved, "--remote-name should use the last segment of the path")
+	os.Remove("TestActionCallDownload.bin")
+
+	err = discardOutput(RunWithArgs, app, "call", "--continue", "download")
+	assert.EqualError(t, err, "--continue requires --output-file or --remote-name")
+	err = discardOutput(RunWithArgs, app, "call", "-O", "-o", file, "download")
+	assert.EqualError(t, err, "--output-file and --remote-name cannot be used together")
+	os.RemoveAll("TestActionCallDownload")
+}
+
+func TestActionCallStream(t *testing.T) {
+	cfgPath := path.Join("TestActionCallStream", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(": keep-alive\n\nevent: update\nid: 1\ndata: {\"n\":1}\n\ndata: hello\ndata: world\n\nid: 3\ndata: bye\n\n"))
+		case "/lines":
+			w.Write([]byte("a\nb\nc\n"))
+			return
+		}
+		w.(http.Flusher).Flush()
+		// keep the stream open until the client goes away
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "events", Method: "GET", Path: "/events"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "lines", Method: "GET", Path: "/lines"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	start := time.Now()
+	output, err := captureOutput(RunWithArgs, app, "call", "--stream", "--max-events", "2", "events")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"event":"update","id":"1","data":{"n":1}}`+"\n"+`{"event":"message","id":"1","data":"hello\nworld"}`+"\n", output)
+	assert.Less(t, time.Since(start), 2*time.Second, "the stream should be closed after --max-events")
+	output, err = captureOutput(RunWithArgs, app, "call", "--stream", "--stream-timeout", "200ms", "events")
+	assert.NoError(t, err, "reaching the stream timeout is not an error")
+	assert.Equal(t, 3, strings.Count(output, "\n"))
+	assert.Contains(t, output, `{"event":"message","id":"3","data":"bye"}`)
+	output, err = captureOutput(RunWithArgs, app, "call", "--stream", "--raw", "--max-events", "1", "events")
+	assert.NoError(t, err)
+	assert.Equal(t, ": keep-alive\n", output, "raw streams should not be parsed")
+	output, err = captureOutput(RunWithArgs, app, "call", "--stream", "--max-events", "2", "lines")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--stream", "lines")
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\n", output)
+
+	_, err = captureOutput(RunWithArgs, app, "call", "--stream", "--stream-timeout", "soon", "events")
+	assert.EqualError(t, err, "stream-timeout must be a positive duration such as 500ms or 10s")
+	_, err = captureOutput(RunWithArgs, app, "call", "--stream", "--select", ".a", "events")
+	assert.Error(t, err)
+	os.RemoveAll("TestActionCallStream")
+}
+
+func TestActionCallWebSocket(t *testing.T) {
+	cfgPath := path.Join("TestActionCallWebSocket", ".sp9rk", "tests")
+	// greets every client, echoes its messages and answers the closing handshake
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		wsWriteFrame(rw, 0x1, []byte("welcome"))
+		wsWriteFrame(rw, 0x9, []byte("ping"))
+		rw.Flush()
+		for {
+			opcode, payload, err := wsReadFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case 0x8:
+				wsWriteFrame(rw, 0x8, payload)
+				rw.Flush()
+				return
+			case 0x1:
+				wsWriteFrame(rw, 0x1, append([]byte("echo: "), payload...))
+				rw.Flush()
+			}
+		}
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+	long := strings.Repeat("x", 300)
+
+	_, err := captureOutput(RunWithArgs, app, "create", "req", "--kind", "websocket", "--path", "/ws", "-H", "X-Token: secret", "--message", "hi {{.name}}", "--message", long, "ws")
+	assert.NoError(t, err)
+	output, err := captureOutput(RunWithArgs, app, "call", "--var", "name=bob", "--max-events", "3", "ws")
+	assert.NoError(t, err)
+	assert.Equal(t, "welcome\necho: hi bob\necho: "+long+"\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "-v", "--var", "name=bob", "--stream-timeout", "300ms", "ws")
+	assert.NoError(t, err, "reaching the stream timeout is not an error")
+	assert.Equal(t, "> hi bob\n> "+long+"\n< welcome\n< echo: hi bob\n< echo: "+long+"\n", output)
+
+	// lines from stdin are sent, and the end of input closes the socket
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--message", "first", "ws")
+	assert.NoError(t, err)
+	stdin := os.Stdin
+	r, w, _ := os.Pipe()
+	os.Stdin = r
+	w.WriteString("one\ntwo\n")
+	w.Close()
+	output, err = captureOutput(RunWithArgs, app, "call", "-i", "ws")
+	os.Stdin = stdin
+	assert.NoError(t, err)
+	assert.Equal(t, "welcome\necho: first\necho: one\necho: two\n", output)
+
+	// websockets go through the proxy with a CONNECT tunnel
+	var tunnel atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		tunnel.Store(r.Host + " " + r.Header.Get("Proxy-Authorization"))
+		backend, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer backend.Close()
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+		rw.Flush()
+		go io.Copy(backend, rw)
+		io.Copy(conn, backend)
+	}))
+	defer proxy.Close()
+	output, err = captureOutput(RunWithArgs, app, "call", "--max-events", "2", "--proxy", strings.Replace(proxy.URL, "://", "://user:pass@", 1), "ws")
+	assert.NoError(t, err)
+	assert.Equal(t, "welcome\necho: first\n", output)
+	assert.Equal(t, strings.TrimPrefix(server.URL, "http://")+" Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), tunnel.Load())
+	_, err = captureOutput(RunWithArgs, app, "call", "--proxy", "socks5://127.0.0.1:1", "ws")
+	assert.EqualError(t, err, "websocket and grpc requests can only use http and https proxies")
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--header", "X-Token: wrong", "ws")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "ws")
+	assert.EqualError(t, err, "server refused the websocket")
+	for _, flag := range []string{"--snapshot", "--select=.id", "--output-file=out.txt", "--retries=2", "--fail"} {
+		_, err = captureOutput(RunWithArgs, app, "call", flag, "ws")
+		assert.EqualError(t, err, strings.Split(flag, "=")[0]+" cannot be used with websocket requests")
+	}
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--assert", "status == 101", "ws")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "ws")
+	assert.EqualError(t, err, "websocket requests cannot have assertions, captures or hooks")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--kind", "carrier-pigeon", "ws")
+	assert.EqualError(t, err, "unsupported request kind carrier-pigeon")
+	os.RemoveAll("TestActionCallWebSocket")
+}
+
+func TestActionCallGraphQL(t *testing.T) {
+	cfgPath := path.Join("TestActionCallGraphQL", ".sp9rk", "tests")
+	typeRef := func(kind, name string) map[string]any { return map[string]any{"kind": kind, "name": name} }
+	nonNull := func(t map[string]any) map[string]any { return map[string]any{"kind": "NON_NULL", "ofType": t} }
+	list := func(t map[string]any) map[string]any { return map[string]any{"kind": "LIST", "ofType": t} }
+	user := typeRef("OBJECT", "User")
+	schema := map[string]any{
+		"queryType":    map[string]any{"name": "Query"},
+		"mutationType": map[string]any{"name": "Mutation"},
+		"types": []any{
+			map[string]any{"kind": "OBJECT", "name": "Query", "fields": []any{
+				map[string]any{"name": "user", "args": []any{map[string]any{"name": "id", "type": nonNull(typeRef("SCALAR", "ID"))}}, "type": user},
+				map[string]any{"name": "users", "args": []any{}, "type": nonNull(list(nonNull(user)))},
+			}},
+			map[string]any{"kind": "OBJECT", "name": "Mutation", "fields": []any{
+				map[string]any{"name": "createUser", "args": []any{map[string]any{"name": "name", "type": nonNull(typeRef("SCALAR", "String"))}}, "type": user},
+				map[string]any{"name": "user", "args": []any{map[string]any{"name": "id", "type": nonNull(typeRef("SCALAR", "ID"))}}, "type": user},
+			}},
+			map[string]any{"kind": "OBJECT", "name": "User", "fields": []any{
+				map[string]any{"name": "id", "args": []any{}, "type": nonNull(typeRef("SCALAR", "ID"))},
+				map[string]any{"name": "name", "args": []any{}, "type": typeRef("SCALAR", "String")},
+				map[string]any{"name": "friends", "args": []any{}, "type": list(user)},
+			}},
+			map[string]any{"kind": "SCALAR", "name": "ID"},
+			map[string]any{"kind": "SCALAR", "name": "String"},
+		},
+	}
+	var last map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&last)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(last["query"].(string), "__schema") {
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"__schema": schema}})
+			return
+		}
+		variables, _ := last["variables"].(map[string]any)
+		if variables["id"] == "1" {
+			w.Write([]byte(`{"data":{"user":{"id":"1","name":"Alice"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"user not found","path":["user"],"locations":[{"line":2,"column":3}]}]}`))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "import", "graphql-schema")
+	assert.NoError(t, err)
+	assert.Equal(t, "Created request user\nCreated request users\nCreated request createUser\nCreated request mutation-user\n", output, "a mutation should not be skipped when a query has its name")
+	reqinfo := new(action.RequestInfo)
+	contents, _ := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "user"))
+	yaml.Unmarshal(contents, reqinfo)
+	assert.Equal(t, "graphql", reqinfo.Kind)
+	assert.Equal(t, "/graphql", reqinfo.Path)
+	assert.Equal(t, "user", reqinfo.OperationName)
+	assert.Equal(t, "{\n  \"id\": null\n}", reqinfo.Variables)
+	assert.Equal(t, `query user($id: ID!) {
+  user(id: $id) {
+    id
+    name
+    friends {
+      id
+      name
+    }
+  }
+}
+`, reqinfo.Document)
+	output, err = captureOutput(RunWithArgs, app, "import", "graphql-schema")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "Skipped user, the request already exists")
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--variables", `{"id": "{{.id}}"}`, "user")
+	assert.NoError(t, err)
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "id=1", "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"user\": {\n    \"id\": \"1\",\n    \"name\": \"Alice\"\n  }\n}\n", output, "only data should be printed")
+	assert.Equal(t, "user", last["operationName"])
+	assert.Equal(t, map[string]any{"id": "1"}, last["variables"])
+	contents, _ = os.ReadFile(action.ReqPath(cfgPath, "TestApp", "mutation-user"))
+	assert.Contains(t, string(contents), "mutation user($id: ID!)")
+	// values are escaped, whether the variables are JSON before rendering or not
+	_, err = captureOutput(RunWithArgs, app, "call", "--var", `id="quoted"`, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": `"quoted"`}, last["variables"])
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--variables", `{"id": {{json .id}}}`, "user")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "--var", `id=a\b`, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"id": `a\b`}, last["variables"])
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "id=2", "user")
+	assert.NoError(t, err, "errors should only fail the call with --fail")
+	assert.Equal(t, "{\n  \"user\": null\n}\n", output, "errors should not be printed to stdout")
+	_, err = captureOutput(RunWithArgs, app, "call", "--fail", "--var", "id=2", "user")
+	assert.EqualError(t, err, "response contains GraphQL errors")
+	// verbose output shows the whole exchange, as for any other POST
+	output, err = captureOutput(RunWithArgs, app, "call", "-v", "--var", "id=2", "user")
+	assert.NoError(t, err)
+	respData := new(action.VerboseCallResponse)
+	assert.NoError(t, yaml.Unmarshal([]byte(output), respData))
+	assert.Equal(t, "POST "+server.URL+"/graphql", respData.Request)
+	assert.Contains(t, respData.RequestBody, `"operationName":"user"`)
+	assert.Equal(t, "200 OK", respData.Status)
+	assert.Contains(t, respData.ResponseBody, "user not found", "errors should be part of the verbose output")
+
+	// documents can be kept in their own file
+	document := path.Join("TestActionCallGraphQL", "user.graphql")
+	os.WriteFile(document, []byte("query user($id: ID!) { user(id: $id) { id } }"), 0644)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "--kind", "graphql", "--path", "/graphql", "--document-file", document, "--variables", `{"id": "1"}`, "fromfile")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "fromfile")
+	assert.NoError(t, err)
+	assert.Equal(t, "query user($id: ID!) { user(id: $id) { id } }", last["query"])
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--variables", `{"id": `, "fromfile")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "fromfile")
+	assert.EqualError(t, err, "graphql variables are not valid JSON")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--document", "{ users { id } }", "fromfile")
+	assert.EqualError(t, err, "a request cannot have both a document and a document file")
+	os.RemoveAll("TestActionCallGraphQL")
+}
+
+func TestActionCallGRPC(t *testing.T) {
+	cfgPath := path.Join("TestActionCallGRPC", ".sp9rk", "tests")
+	newServer := func(reflect bool) string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		server := grpc.NewServer()
+		healthServer := health.NewServer()
+		healthServer.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+		healthpb.RegisterHealthServer(server, healthServer)
+		if reflect {
+			reflection.Register(server)
+		}
+		go server.Serve(lis)
+		t.Cleanup(server.Stop)
+		return "http://" + lis.Addr().String()
+	}
+	os.MkdirAll(cfgPath, 0700)
+	proto := path.Join("TestActionCallGRPC", "health.proto")
+	os.WriteFile(proto, []byte(`syntax = "proto3";
+package grpc.health.v1;
+message HealthCheckRequest { string service = 1; }
+message HealthCheckResponse {
+  enum ServingStatus { UNKNOWN = 0; SERVING = 1; NOT_SERVING = 2; SERVICE_UNKNOWN = 3; }
+  ServingStatus status = 1;
+}
+service Health {
+  rpc Check(HealthCheckRequest) returns (HealthCheckResponse);
+  rpc Watch(HealthCheckRequest) returns (stream HealthCheckResponse);
+}
+`), 0644)
+	app := app.New(cfgPath, http.Client{})
+	_, err := captureOutput(RunWithArgs, app, "create", "app", "--host", newServer(true), "TestApp")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "app", "--host", newServer(false), "--proto", proto, "ProtoApp")
+	assert.NoError(t, err)
+	appinfo := new(action.AppInfo)
+	contents, _ := os.ReadFile(action.AppInfoFilePath(cfgPath, "ProtoApp"))
+	yaml.Unmarshal(contents, appinfo)
+	abs, _ := filepath.Abs(proto)
+	assert.Equal(t, []string{abs}, appinfo.GRPC.ProtoFiles, "proto files should be saved as absolute paths")
+	for _, a := range []string{"TestApp", "ProtoApp"} {
+		_, err = captureOutput(RunWithArgs, app, "create", "req", "-a", a, "--kind", "grpc", "--rpc", "grpc.health.v1.Health/Check", "--body", `{"service": "{{.svc}}"}`, "check")
+		assert.NoError(t, err)
+		_, err = captureOutput(RunWithArgs, app, "create", "req", "-a", a, "--kind", "grpc", "--rpc", "grpc.health.v1.Health.Watch", "--body", `{"service": "svc"}`, "watch")
+		assert.NoError(t, err)
+	}
+
+	for _, a := range []string{"TestApp", "ProtoApp"} {
+		output, err := captureOutput(RunWithArgs, app, "call", "-a", a, "--var", "svc=svc", "check")
+		assert.NoError(t, err, a)
+		assert.Equal(t, "{\n  \"status\": \"SERVING\"\n}\n", output, a)
+		_, err = captureOutput(RunWithArgs, app, "call", "-a", a, "--var", "svc=other", "check")
+		assert.EqualError(t, err, "grpc call failed: NotFound: unknown service", a)
+		output, err = captureOutput(RunWithArgs, app, "call", "-a", a, "--max-events", "1", "watch")
+		assert.NoError(t, err, a)
+		assert.Equal(t, "{\n  \"status\": \"SERVING\"\n}\n", output, a)
+	}
+	// calls are saved to the history, but cannot be replayed
+	entries, err := action.ReadHistory(cfgPath)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 6) {
+		assert.Equal(t, "GRPC", entries[0].Method)
+		assert.True(t, strings.HasSuffix(entries[0].URL, "/grpc.health.v1.Health/Check"), entries[0].URL)
+		assert.Equal(t, `{"service": "svc"}`, entries[0].RequestBody)
+		assert.Equal(t, "OK", entries[0].Status)
+		assert.Equal(t, "{\n  \"status\": \"SERVING\"\n}\n", entries[0].ResponseBody)
+		assert.Equal(t, "NotFound", entries[1].Status)
+		assert.Equal(t, "grpc call failed: NotFound: unknown service", entries[1].Error)
+	}
+	_, err = captureOutput(RunWithArgs, app, "history", "replay", "1")
+	assert.EqualError(t, err, "grpc calls cannot be replayed, call check again instead")
+
+	// calls go through the proxy with a CONNECT tunnel
+	var tunnels atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		tunnels.Add(1)
+		backend, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer backend.Close()
+		conn, rw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+		rw.Flush()
+		go io.Copy(backend, rw)
+		io.Copy(conn, backend)
+	}))
+	defer proxy.Close()
+	output, err := captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "--proxy", proxy.URL, "--var", "svc=svc", "check")
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n  \"status\": \"SERVING\"\n}\n", output)
+	assert.EqualValues(t, 1, tunnels.Load(), "the call should go through the proxy")
+	_, err = captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "--proxy", "socks5://127.0.0.1:1", "--var", "svc=svc", "check")
+	assert.EqualError(t, err, "websocket and grpc requests can only use http and https proxies")
+
+	_, err = captureOutput(RunWithArgs, app, "call", "-a", "ProtoApp", "--var", "svc={{", "check")
+	assert.Error(t, err)
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "-a", "TestApp", "--body", `{"serv": "svc"}`, "check")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "check")
+	assert.ErrorContains(t, err, "body is not a valid grpc.health.v1.HealthCheckRequest")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "-a", "TestApp", "--rpc", "grpc.health.v1.Health/Missing", "check")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "-a", "TestApp", "check")
+	assert.EqualError(t, err, "service grpc.health.v1.Health does not have a method Missing")
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "-a", "TestApp", "--rpc", "Check", "check")
+	assert.EqualError(t, err, "rpc must be a full method name such as package.Service/Method")
+	os.RemoveAll("TestActionCallGRPC")
+}
+
+func TestActionMock(t *testing.T) {
+	cfgPath := path.Join("TestActionMock", ".sp9rk", "tests")
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: "https://api.example.com/v1",
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "user", Method: "GET", Path: "/users/{{.id}}"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "me", Method: "GET", Path: "/users/me?full=true"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "snapshotted", Method: "POST", Path: "/items"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "empty", Method: "DELETE", Path: "/items"})
+	action.WriteSnapshot(cfgPath, "TestApp", "snapshotted", &action.Snapshot{
+		StatusCode: 201,
+		Headers:    []action.Header{{Name: "Content-Length", Value: "99"}, {Name: "Location", Value: "/items/1"}},
+		Body:       `{"id":1}`,
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--example-body", `{"id":7}`, "--example-header", "Content-Type: application/json", "user"))
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--example-status", "203", "--example-body", "me", "--example-latency", "100ms", "me"))
+	assert.Error(t, RunWithArgs(app, "edit", "req", "--example-status", "1000", "me"))
+	assert.Error(t, RunWithArgs(app, "edit", "req", "--example-latency", "soon", "me"))
+
+	// find a free port
+	l, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	base := "http://localhost:" + strconv.Itoa(port)
+
+	c, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- app.RunContext(c, []string{"sp9rk", "mock", "--port", strconv.Itoa(port)}) }()
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get(base + "/v1/users/42"); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `{"id":7}`, string(body))
+	}
+
+	start := time.Now()
+	resp, err = http.Get(base + "/v1/users/me")
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, 203, resp.StatusCode, "literal segments should win over template segments")
+		assert.Equal(t, "me", string(body))
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	}
+
+	resp, err = http.Post(base+"/v1/items", "application/json", nil)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, 201, resp.StatusCode, "requests without an example should serve their snapshot")
+		assert.Equal(t, "/items/1", resp.Header.Get("Location"))
+		assert.Equal(t, `{"id":1}`, string(body))
+	}
+
+	req, _ := http.NewRequest("DELETE", base+"/v1/items", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 501, resp.StatusCode)
+	}
+	resp, err = http.Get(base + "/v1/items")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 404, resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("mock server did not shut down")
+	}
+	assert.Error(t, RunWithArgs(app, "mock", "NoSuchApp"))
+	os.RemoveAll("TestActionMock")
+}
+
+func TestActionRecord(t *testing.T) {
+	cfgPath := path.Join("TestActionRecord", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Host", r.Host)
+		if r.Method == "POST" {
+			w.WriteHeader(201)
+		}
+		w.Write([]byte(`{"path":"` + r.URL.Path + `","body":"` + string(body) + `"}`))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: "http://localhost:1",
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "existing", Method: "GET", Path: "/existing"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	l, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	c, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- app.RunContext(c, []string{"sp9rk", "record", "--listen", addr, "--target", server.URL})
+	}()
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = http.Get("http://" + addr + "/users/42?full=true"); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, `{"path":"/users/42","body":""}`, string(body), "traffic should be forwarded to the target")
+	}
+	resp, err = http.Get("http://" + addr + "/users/42/")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	req, _ := http.NewRequest("POST", "http://"+addr+"/items", strings.NewReader("new"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=s3cret")
+	req.Header.Set("Proxy-Authorization", "Basic cHJveHk6cGFzcw==")
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, 201, resp.StatusCode)
+	}
+	resp, err = http.Get("http://" + addr + "/existing")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("recording proxy did not shut down")
+	}
+
+	files, _ := os.ReadDir(action.AppPath(cfgPath, "TestApp"))
+	assert.Len(t, files, 4, "each distinct request should be saved once")
+	reqinfo := new(action.RequestInfo)
+	contents, err := os.ReadFile(action.ReqPath(cfgPath, "TestApp", "get-users-42"))
+	assert.NoError(t, err)
+	assert.NoError(t, yaml.Unmarshal(contents, reqinfo))
+	assert.Equal(t, "GET", reqinfo.Method)
+	assert.Equal(t, "/users/42", reqinfo.Path)
+	assert.Equal(t, action.Query{"full": {"true"}}, reqinfo.Query)
+	if assert.NotNil(t, reqinfo.Example) {
+		assert.Equal(t, 200, reqinfo.Example.Status)
+		assert.Equal(t, `{"path":"/users/42","body":""}`, reqinfo.Example.Body)
+		assert.Contains(t, reqinfo.Example.Headers, action.Header{Name: "Content-Type", Value: "application/json"})
+		assert.Contains(t, reqinfo.Example.Headers, action.Header{Name: "X-Host", Value: strings.TrimPrefix(server.URL, "http://")})
+	}
+	reqinfo = new(action.RequestInfo)
+	contents, err = os.ReadFile(action.ReqPath(cfgPath, "TestApp", "post-items"))
+	assert.NoError(t, err)
+	assert.NoError(t, yaml.Unmarshal(contents, reqinfo))
+	assert.Equal(t, "new", reqinfo.Body)
+	assert.Equal(t, []action.Header{{Name: "Authorization", Value: "{{.authorization}}"}, {Name: "Cookie", Value: "{{.cookie}}"}}, reqinfo.Headers, "credentials should not be saved")
+	assert.NotContains(t, string(contents), "secret")
+	assert.NotContains(t, string(contents), "s3cret")
+	if assert.NotNil(t, reqinfo.Example) {
+		assert.Equal(t, 201, reqinfo.Example.Status)
+		assert.Equal(t, `{"path":"/items","body":"new"}`, reqinfo.Example.Body)
+	}
+
+	assert.EqualError(t, RunWithArgs(app, "record", "--target", "localhost:8080"), "target must be an http or https URL")
+	os.RemoveAll("TestActionRecord")
+}
+
+func TestActionBench(t *testing.T) {
+	cfgPath := path.Join("TestActionBench", ".sp9rk", "tests")
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%10 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(r.URL.Query().Get("id")))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "get", Method: "GET", Path: "/?id={{.id}}"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "ws", Kind: "websocket", Method: "GET", Path: "/"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "bench", "-c", "4", "-n", "50", "--var", "id=1", "get")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 50, hits.Load())
+	assert.Contains(t, output, "Requests:\t50 in ")
+	assert.Contains(t, output, "Errors:\t\t5\n")
+	assert.Contains(t, output, "\t200 OK\t45\n")
+	assert.Contains(t, output, "\t503 Service Unavailable\t5\n")
+	for _, p := range []string{"p50", "p90", "p99", "max"} {
+		assert.Contains(t, output, "\t"+p+"\t")
+	}
+	assert.Contains(t, output, "Histogram:\n")
+
+	hits.Store(0)
+	start := time.Now()
+	output, err = captureOutput(RunWithArgs, app, "bench", "--duration", "500ms", "--rate", "10/s", "--var", "id=1", "get")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.GreaterOrEqual(t, hits.Load(), int64(3), "the rate should be close to 10/s")
+	assert.LessOrEqual(t, hits.Load(), int64(7), "the rate should be close to 10/s")
+
+	// the proxy is chosen as for a call
+	var proxied atomic.Int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+		w.Write([]byte(r.URL.String()))
+	}))
+	defer proxy.Close()
+	output, err = captureOutput(RunWithArgs, app, "bench", "-n", "5", "--proxy", proxy.URL, "--var", "id=1", "get")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, proxied.Load(), "every request should go through the proxy")
+	assert.Contains(t, output, "\t200 OK\t5\n")
+	assert.Error(t, RunWithArgs(app, "bench", "--proxy-user", "user:pass", "--var", "id=1", "get"), "saved proxy settings are changed with edit app")
+
+	_, err = captureOutput(RunWithArgs, app, "bench", "get")
+	assert.Error(t, err, "requests that fail to render should fail before the benchmark starts")
+	_, err = captureOutput(RunWithArgs, app, "bench", "--rate", "fast", "--var", "id=1", "get")
+	assert.EqualError(t, err, "rate must be a number of requests per unit of time such as 200/s")
+	_, err = captureOutput(RunWithArgs, app, "bench", "-c", "0", "--var", "id=1", "get")
+	assert.EqualError(t, err, "concurrency must be at least 1")
+	_, err = captureOutput(RunWithArgs, app, "bench", "ws")
+	assert.EqualError(t, err, "only http and graphql requests can be benchmarked")
+	os.RemoveAll("TestActionBench")
+}
+
+func TestActionCallBatch(t *testing.T) {
+	cfgPath := path.Join("TestActionCallBatch", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte("slow\nresponse"))
+		case "/fast":
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("fast"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "slow", Method: "GET", Path: "/slow"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "fast", Method: "GET", Path: "/fast"})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:   "broken",
+		Method: "GET",
+		Path:   "/broken",
+		Assert: []action.Assertion{{Select: "status", Op: "==", Value: "200"}},
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	start := time.Now()
+	output, err := captureOutput(RunWithArgs, app, "call", "slow", "fast")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 380*time.Millisecond, "requests should be called concurrently")
+	assert.Equal(t, "[slow] slow\n[slow] response\n[fast] fast\n", output, "output should be in the order requests were given")
+
+	output, err = captureOutput(RunWithArgs, app, "call", "--interleave", "slow", "fast")
+	assert.NoError(t, err)
+	assert.Equal(t, "[fast] fast\n[slow] slow\n[slow] response\n", output, "output should be printed as it arrives")
+
+	start = time.Now()
+	output, err = captureOutput(RunWithArgs, app, "call", "--parallel", "1", "slow", "fast")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond, "--parallel should limit concurrent calls")
+	assert.Equal(t, "[slow] slow\n[slow] response\n[fast] fast\n", output)
+
+	output, err = captureOutput(RunWithArgs, app, "call", "--all", "-a", "TestApp")
+	assert.EqualError(t, err, "1 of 3 requests failed")
+	assert.Equal(t, "[broken] \n[fast] fast\n[slow] slow\n[slow] response\n", output, "--all should call every request in name order")
+
+	_, err = captureOutput(RunWithArgs, app, "call", "--all", "slow")
+	assert.EqualError(t, err, "--all cannot be used with request names")
+	_, err = captureOutput(RunWithArgs, app, "call", "-o", "out.txt", "slow", "fast")
+	assert.Error(t, err)
+	_, err = captureOutput(RunWithArgs, app, "call", "slow", "missing")
+	assert.EqualError(t, err, "1 of 2 requests failed")
+	os.RemoveAll("TestActionCallBatch")
+}
+
+func TestActionCallData(t *testing.T) {
+	cfgPath := path.Join("TestActionCallData", ".sp9rk", "tests")
+	var ids atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&user); err != nil || user.Age < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":` + strconv.Itoa(int(ids.Add(1))) + `,"name":"` + user.Name + `"}`))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:    "CreateUser",
+		Method:  "POST",
+		Path:    "/users",
+		Body:    `{"name": "{{.name}}", "age": {{.age}}}`,
+		Assert:  []action.Assertion{{Select: "status", Op: "==", Value: "201"}},
+		Capture: map[string]string{"id": ".id"},
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	dir := filepath.Join("TestActionCallData", "files")
+	os.MkdirAll(dir, 0700)
+	csvFile := filepath.Join(dir, "users.csv")
+	os.WriteFile(csvFile, []byte("name,age\nalice,30\nbob,-1\ncarol,25\n"), 0600)
+	results := filepath.Join(dir, "results.csv")
+	output, err := captureOutput(RunWithArgs, app, "call", "--data", csvFile, "--results", results, "--parallel", "1", "CreateUser")
+	assert.EqualError(t, err, "1 of 3 rows failed")
+	assert.Equal(t, "[row 1] {\"id\":1,\"name\":\"alice\"}\n[row 2] \n[row 3] {\"id\":2,\"name\":\"carol\"}\n", output)
+	contents, err := os.ReadFile(results)
+	assert.NoError(t, err)
+	assert.Equal(t, "row,status,error,id\n1,201,,1\n2,400,1 of 1 assertion(s) failed,\n3,201,,2\n", string(contents))
+	vars, err := action.ReadVariables(cfgPath, "TestApp")
+	assert.NoError(t, err)
+	assert.NotContains(t, vars, "id", "values captured from rows should not be stored")
+
+	jsonFile := filepath.Join(dir, "users.json")
+	os.WriteFile(jsonFile, []byte(`[{"name": "dave", "age": 40}, {"name": "erin", "age": null}]`), 0600)
+	results = filepath.Join(dir, "results.json")
+	_, err = captureOutput(RunWithArgs, app, "call", "--data", jsonFile, "--results", results, "CreateUser")
+	assert.EqualError(t, err, "1 of 2 rows failed", "null should be bound as an empty string")
+	contents, err = os.ReadFile(results)
+	assert.NoError(t, err)
+	var out []map[string]any
+	assert.NoError(t, json.Unmarshal(contents, &out))
+	if assert.Len(t, out, 2) {
+		assert.Equal(t, map[string]any{"row": 1.0, "status": 201.0, "captured": map[string]any{"id": "3"}}, out[0])
+		assert.Equal(t, map[string]any{"row": 2.0, "status": 400.0, "error": "1 of 1 assertion(s) failed"}, out[1])
+	}
+
+	start := time.Now()
+	_, err = captureOutput(RunWithArgs, app, "call", "--data", csvFile, "--rate", "10/s", "--var", "age=1", "CreateUser")
+	assert.EqualError(t, err, "1 of 3 rows failed", "row values should override --var")
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond, "--rate should space out calls")
+
+	_, err = captureOutput(RunWithArgs, app, "call", "--data", csvFile, "CreateUser", "CreateUser")
+	assert.EqualError(t, err, "--data can only be used with a single request")
+	_, err = captureOutput(RunWithArgs, app, "call", "--results", results, "CreateUser")
+	assert.EqualError(t, err, "--results can only be used with --data")
+	os.WriteFile(jsonFile, []byte(`{"name": "dave"}`), 0600)
+	_, err = captureOutput(RunWithArgs, app, "call", "--data", jsonFile, "CreateUser")
+	assert.EqualError(t, err, "data file must be a JSON array of objects")
+	os.RemoveAll("TestActionCallData")
+}
+
+func TestActionCallHooks(t *testing.T) {
+	cfgPath := path.Join("TestActionCallHooks", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("X-Signature") + " " + r.Header.Get("X-Remove") + " " + string(body)))
+	}))
+	defer server.Close()
+	dir, _ := filepath.Abs(filepath.Join("TestActionCallHooks", "hooks"))
+	os.MkdirAll(dir, 0700)
+	input := filepath.Join(dir, "input.json")
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:    "signed",
+		Method:  "POST",
+		Path:    "/sign",
+		Headers: []action.Header{{Name: "X-Remove", Value: "me"}},
+		Body:    "{{.msg}}",
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	pre := `cat > ` + input + `; echo '{"headers": {"X-Signature": ["abc"], "X-Remove": []}, "body": "changed", "vars": {"nonce": "1"}}'`
+	assert.NoError(t, RunWithArgs(app, "edit", "req", "--pre-request", pre, "--post-response", `echo '{"vars": {"seen": "yes"}}'`, "signed"))
+	output, err := captureOutput(RunWithArgs, app, "call", "--var", "msg=hello", "signed")
+	assert.NoError(t, err)
+	assert.Equal(t, "POST /sign abc  changed\n", output, "the pre_request hook should change the request")
+	var in map[string]any
+	contents, _ := os.ReadFile(input)
+	assert.NoError(t, json.Unmarshal(contents, &in))
+	assert.Equal(t, "POST", in["method"])
+	assert.Equal(t, server.URL+"/sign", in["url"])
+	assert.Equal(t, "hello", in["body"])
+	assert.Equal(t, "signed", in["request"])
+	assert.Equal(t, "hello", in["vars"].(map[string]any)["msg"])
+	vars, _ := action.ReadVariables(cfgPath, "TestApp")
+	assert.Equal(t, "1", vars["nonce"])
+	assert.Equal(t, "yes", vars["seen"], "variables set by the post_response hook should be stored")
+	// dry runs run the pre_request hook, but do not store its variables
+	assert.NoError(t, action.WriteVariables(cfgPath, "TestApp", map[string]string{"nonce": "0"}))
+	output, err = captureOutput(RunWithArgs, app, "call", "--dry-run", "--var", "msg=hello", "signed")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "X-Signature: abc")
+	vars, _ = action.ReadVariables(cfgPath, "TestApp")
+	assert.Equal(t, "0", vars["nonce"], "a dry run should not store variables")
+
+	// app hooks run before the request's pre_request hook
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--pre-request", `echo '{"method": "PUT", "url": "`+server.URL+`/app"}'`, "--post-response", `echo '{"error": "bad response"}'`, "TestApp"))
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "msg=hello", "signed")
+	assert.EqualError(t, err, "bad response", "post_response hooks should be able to fail the call")
+	assert.Equal(t, "PUT /app abc  changed\n", output)
+
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--pre-request", "exit 3", "--post-response", "", "TestApp"))
+	_, err = captureOutput(RunWithArgs, app, "call", "--var", "msg=hello", "signed")
+	assert.EqualError(t, err, "pre_request hook exited with status 3")
+	assert.NoError(t, RunWithArgs(app, "edit", "app", "--pre-request", "echo not json", "TestApp"))
+	_, err = captureOutput(RunWithArgs, app, "call", "--var", "msg=hello", "signed")
+	assert.EqualError(t, err, "pre_request hook did not answer with a JSON object")
+	for _, method := range []string{"", "GET /", "P@ST"} {
+		answer, _ := json.Marshal(map[string]string{"method": method})
+		assert.NoError(t, RunWithArgs(app, "edit", "app", "--pre-request", "echo '"+string(answer)+"'", "TestApp"))
+		_, err = captureOutput(RunWithArgs, app, "call", "--var", "msg=hello", "signed")
+		assert.EqualError(t, err, "pre_request hook answered with an invalid method", method)
+	}
+	os.RemoveAll("TestActionCallHooks")
+}
+
+func TestActionCallTemplateFuncs(t *testing.T) {
+	cfgPath := path.Join("TestActionCallTemplateFuncs", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + "\n" + r.Header.Get("Idempotency-Key") + "\n" + string(body)))
+	}))
+	defer server.Close()
+	file := filepath.Join("TestActionCallTemplateFuncs", "payload.txt")
+	os.MkdirAll("TestActionCallTemplateFuncs", 0700)
+	os.WriteFile(file, []byte("from a file"), 0600)
+	t.Setenv("SP9RK_TEST_TOKEN", "secret")
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:    "funcs",
+		Method:  "POST",
+		Path:    "/items/{{randomInt 7 7}}",
+		Headers: []action.Header{{Name: "Idempotency-Key", Value: "{{uuid}}"}},
+		Body:    `{{env "SP9RK_TEST_TOKEN" | base64}} {{sha256 "abc"}} {{file "` + file + `"}} {{randomEmail}} {{unix}} {{now "2006"}}`,
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "missing", Method: "GET", Path: `/{{env "SP9RK_TEST_UNSET"}}`})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "range", Method: "GET", Path: `/{{randomInt 5 1}}`})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "call", "funcs")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "/items/7", lines[0])
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, lines[1])
+		assert.Regexp(t, `^c2VjcmV0 ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad from a file user-[0-9a-f]{12}@example\.com \d{10} \d{4}$`, lines[2])
+	}
+	second, err := captureOutput(RunWithArgs, app, "call", "funcs")
+	assert.NoError(t, err)
+	assert.NotEqual(t, output, second, "functions should be evaluated on every call")
+
+	_, err = captureOutput(RunWithArgs, app, "call", "missing")
+	assert.ErrorContains(t, err, "environment variable SP9RK_TEST_UNSET is not set")
+	_, err = captureOutput(RunWithArgs, app, "call", "range")
+	assert.ErrorContains(t, err, "randomInt needs a maximum of at least its minimum")
+	os.RemoveAll("TestActionCallTemplateFuncs")
+}
+
+func TestActionCallDryRun(t *testing.T) {
+	cfgPath := path.Join("TestActionCallDryRun", ".sp9rk", "tests")
+	var hits atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name: "TestApp",
+		Host: server.URL + "/api",
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{
+		Name:    "create",
+		Method:  "POST",
+		Path:    "/users/{{.id}}",
+		Query:   action.Query{"tag": {"a b"}},
+		Headers: []action.Header{{Name: "Authorization", Value: "Bearer {{.token}}"}},
+		Body:    `{"id": {{.id}}}`,
+		Hooks:   action.Hooks{PreRequest: `echo '{"headers": {"X-Signature": ["abc"]}}'`},
+	})
+	action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "ws", Kind: "websocket", Method: "GET", Path: "/ws"})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	output, err := captureOutput(RunWithArgs, app, "call", "--dry-run", "--var", "id=7", "--var", "token=t0k", "create")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, hits.Load(), "a dry run should not send the request")
+	assert.True(t, strings.HasPrefix(output, "POST /api/users/7?tag=a+b HTTP/1.1\r\nHost: "+strings.TrimPrefix(server.URL, "http://")+"\r\n"), output)
+	assert.Contains(t, output, "Authorization: Bearer t0k\r\n")
+	assert.Contains(t, output, "X-Signature: abc\r\n", "hooks should run before the request is printed")
+	assert.Contains(t, output, "Content-Length: 9\r\n")
+	assert.True(t, strings.HasSuffix(output, "\r\n\r\n{\"id\": 7}\n"), output)
+	entries, _ := action.ReadHistory(cfgPath)
+	assert.Empty(t, entries, "a dry run should not be saved to the history")
+
+	_, err = captureOutput(RunWithArgs, app, "call", "--dry-run", "create")
+	assert.Error(t, err, "variables should still be resolved")
+	_, err = captureOutput(RunWithArgs, app, "call", "--dry-run", "ws")
+	assert.EqualError(t, err, "--dry-run only supports http and graphql requests")
+	os.RemoveAll("TestActionCallDryRun")
+}
+
+func TestActionCallDefaults(t *testing.T) {
+	cfgPath := path.Join("TestActionCallDefaults", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join([]string{r.Header.Get("Accept"), r.Header.Get("Authorization"), r.Header.Get("X-Client"), r.URL.RawQuery}, "|")))
+	}))
+	defer server.Close()
+	os.MkdirAll(cfgPath, 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	_, err := captureOutput(RunWithArgs, app, "create", "app", "--host", server.URL, "--default-header", "accept: application/json", "--default-header", "X-Client: sp9rk", "--default-query", "v=2", "--default-timeout", "5s", "--auth-bearer", "{{.token}}", "TestApp")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "-a", "TestApp", "--path", "/a", "inherits")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "-a", "TestApp", "--path", "/b", "--header", "x-client: other", "--query", "v=3", "--unset-header", "authorization", "overrides")
+	assert.NoError(t, err)
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+
+	output, err := captureOutput(RunWithArgs, app, "call", "--var", "token=t0k", "inherits")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json|Bearer t0k|sp9rk|v=2\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "overrides")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json||other|v=3\n", output, "request headers and query should override defaults")
+	_, err = captureOutput(RunWithArgs, app, "call", "inherits")
+	assert.Error(t, err, "the token variable is not set")
+
+	output, err = captureOutput(RunWithArgs, app, "info", "req", "--resolved", "overrides")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "timeout: 5s")
+	assert.Contains(t, output, "name: Accept")
+	assert.NotContains(t, output, "Authorization")
+	assert.NotContains(t, output, "unset_headers")
+	output, err = captureOutput(RunWithArgs, app, "info", "req", "overrides")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "unset_headers:")
+	assert.NotContains(t, output, "Accept")
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "app", "--remove-default-header", "x-client", "--remove-default-query", "v", "--auth-basic", "me:secret", "TestApp")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "-a", "TestApp", "--inherit-header", "authorization", "overrides")
+	assert.NoError(t, err)
+	output, err = captureOutput(RunWithArgs, app, "call", "overrides")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json|Basic bWU6c2VjcmV0|other|v=3\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "inherits")
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json|Basic bWU6c2VjcmV0||\n", output)
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "app", "--auth-basic", "nocolon", "TestApp")
+	assert.EqualError(t, err, "basic auth must be in the form username:password")
+	_, err = captureOutput(RunWithArgs, app, "edit", "app", "--default-timeout", "soon", "TestApp")
+	assert.EqualError(t, err, "default timeout must be a positive duration such as 500ms or 10s")
+	os.RemoveAll("TestActionCallDefaults")
+}
+
+func TestActionGroups(t *testing.T) {
+	cfgPath := path.Join("TestActionGroups", ".sp9rk", "tests")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join([]string{r.URL.Path, r.Header.Get("Accept"), r.Header.Get("X-Team")}, "|")))
+	}))
+	defer server.Close()
+	action.WriteAppFiles(cfgPath, &action.AppInfo{
+		Name:     "TestApp",
+		Host:     server.URL,
+		Defaults: action.RequestDefaults{Headers: []action.Header{{Name: "Accept", Value: "text/plain"}}},
+	})
+	os.WriteFile(path.Join(cfgPath, "current_app"), []byte("TestApp"), 0700)
+	app := app.New(cfgPath, http.Client{})
+
+	_, err := captureOutput(RunWithArgs, app, "create", "group", "--description", "user management", "-H", "Accept: application/json", "--var", "id=1", "users")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "group", "-H", "X-Team: admins", "--var", "id=2", "users/admin")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "--path", "/users/{{.id}}", "users/get")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "--path", "/admin/{{.id}}", "--description", "remove a user", "users/admin/delete")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "--path", "/health", "health")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "users/../escape")
+	assert.Error(t, err, "request names must not leave the app")
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "users")
+	assert.EqualError(t, err, "a group with that name already exists")
+	_, err = captureOutput(RunWithArgs, app, "create", "group", "health")
+	assert.EqualError(t, err, "a request with that name already exists")
+	_, err = captureOutput(RunWithArgs, app, "create", "req", "health/check")
+	assert.EqualError(t, err, "a request named health already exists")
+	// requests and groups saved by other commands, such as record, are checked too
+	assert.EqualError(t, action.WriteRequestFiles(cfgPath, "TestApp", &action.RequestInfo{Name: "users"}), "a group with that name already exists")
+	assert.EqualError(t, action.WriteGroupInfo(cfgPath, "TestApp", &action.GroupInfo{Name: "health"}), "a request with that name already exists")
+	if info, err := os.Stat(action.GroupInfoFilePath(cfgPath, "TestApp", "users")); assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	output, err := captureOutput(RunWithArgs, app, "list", "req")
+	assert.NoError(t, err)
+	assert.Equal(t, "health\nusers/: user management\n├── admin/\n│   └── delete: remove a user\n└── get\n", output)
+	output, err = captureOutput(RunWithArgs, app, "list")
+	assert.NoError(t, err)
+	assert.Equal(t, "TestApp:\n\t- health\n\t- users/: user management\n\t\t- admin/\n\t\t\t- delete: remove a user\n\t\t- get\n", output)
+
+	output, err = captureOutput(RunWithArgs, app, "call", "users/get")
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/1|application/json|\n", output, "group headers should replace the app's defaults")
+	output, err = captureOutput(RunWithArgs, app, "call", "users/admin/delete")
+	assert.NoError(t, err)
+	assert.Equal(t, "/admin/2|application/json|admins\n", output, "inner groups should override outer ones")
+	output, err = captureOutput(RunWithArgs, app, "call", "--var", "id=3", "users/admin/delete")
+	assert.NoError(t, err)
+	assert.Equal(t, "/admin/3|application/json|admins\n", output, "--var should override group variables")
+	output, err = captureOutput(RunWithArgs, app, "call", "health")
+	assert.NoError(t, err)
+	assert.Equal(t, "/health|text/plain|\n", output)
+	output, err = captureOutput(RunWithArgs, app, "call", "--all")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "[users/admin/delete] /admin/2")
+
+	_, err = captureOutput(RunWithArgs, app, "edit", "group", "--remove-header", "x-team", "--remove-var", "id", "users/admin")
+	assert.NoError(t, err)
+	_, err = captureOutput(RunWithArgs, app, "edit", "req", "--description", "delete a user", "users/admin/delete")
+	assert.NoError(t, err)
+	output, err = captureOutput(RunWithArgs, app, "call", "users/admin/delete")
+	assert.NoError(t, err)
+	assert.Equal(t, "/admin/1|application/json|\n", output)
+	output, err = captureOutput(RunWithArgs, app, "info", "req", "--resolved", "users/admin/delete")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "description: delete a user")
+	assert.Contains(t, output, "value: application/json")
+
+	_, err = captureOutput(RunWithArgs, app, "delete", "req", "--confirm", "users/get")
+	assert.NoError(t, err)
+	_, err = os.Stat(action.ReqPath(cfgPath, "TestApp", "users/get"))
+	assert.Error(t, err)
+	output, err = captureOutput(RunWithArgs, app, "delete", "group", "--confirm", "users")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "group users in application TestApp and 1 associated request(s)")
+	output, err = captureOutput(RunWithArgs, app, "list", "req")
+	assert.NoError(t, err)
+	assert.Equal(t, "health\n", output)
+	_, err = captureOutput(RunWithArgs, app, "edit", "group", "users")
+	assert.EqualError(t, err, "group does not exist")
+	os.RemoveAll("TestActionGroups")
+}
+
 // TODO test redirects
 func TestActionCallRedirects(t *testing.T) {
 	cfgPath := path.Join("TestActionCallLocation", ".sp9rk", "tests")
@@ -606,13 +2571,67 @@ func RunWithArgs(app *cli.App, args ...string) error {
 	a = append(a, args...)
 	return app.Run(a)
 }
+
+// for commands whose output is too large for captureOutput's pipe
+func discardOutput(f func(*cli.App, ...string) error, app *cli.App, args ...string) error {
+	orig := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	err := f(app, args...)
+	os.Stdout.Close()
+	os.Stdout = orig
+	return err
+}
 func captureOutput(f func(*cli.App, ...string) error, app *cli.App, args ...string) (string, error) {
 	orig := os.Stdout
 	r, w, _ := os.Pipe()
+	// read while f runs so output larger than the pipe buffer does not block
+	read := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(r)
+		read <- out
+	}()
 	os.Stdout = w
 	err := f(app, args...)
 	os.Stdout = orig
 	w.Close()
-	out, _ := io.ReadAll(r)
-	return string(out), err
+	return string(<-read), err
+}
+
+// Server side websocket framing used by the test server. Server frames are
+// never masked and client frames always are.
+func wsWriteFrame(w io.Writer, opcode byte, payload []byte) {
+	header := []byte{0x80 | opcode}
+	if len(payload) < 126 {
+		header = append(header, byte(len(payload)))
+	} else {
+		header = append(header, 126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	w.Write(append(header, payload...))
+}
+
+func wsReadFrame(r io.Reader) (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(h[1] & 0x7f)
+	if n == 126 {
+		var l [2]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return 0, nil, err
+		}
+		n = int(l[0])<<8 | int(l[1])
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return h[0] & 0x0f, payload, nil
 }
@@ -0,0 +1,200 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// operators an assertion may use
+var assertionOps = []string{"==", "!=", "~="}
+
+// A check run against every response of a request. Select is a path or XPath
+// understood by --select, or "status" for the status code. Without an Op the
+// selection only has to match something.
+type Assertion struct {
+	Select string `yaml:"select"`
+	Op     string `yaml:"op,omitempty"`
+	Value  string `yaml:"value,omitempty"`
+}
+
+func (a Assertion) String() string {
+	if a.Op == "" {
+		return a.Select
+	}
+	return a.Select + " " + a.Op + " " + a.Value
+}
+
+// Parses assertions such as `.data.id == 42`, `status != 500`,
+// `.name ~= ^a` or just `.data.token`. Quoted values are unquoted.
+func ParseAssertion(raw string) (Assertion, error) {
+	invalid := errors.New("malformed assertion " + raw)
+	a := Assertion{Select: strings.TrimSpace(raw)}
+	// the first operator wins so values may contain operators themselves
+	at := -1
+	for _, op := range assertionOps {
+		if i := strings.Index(raw, op); i >= 0 && (at < 0 || i < at) {
+			at = i
+			a = Assertion{Select: strings.TrimSpace(raw[:i]), Op: op, Value: strings.TrimSpace(raw[i+len(op):])}
+		}
+	}
+	if a.Select == "" {
+		return a, invalid
+	}
+	if a.Select != "status" && !strings.HasPrefix(a.Select, "/") {
+		if _, err := parsePath(a.Select); err != nil {
+			return a, err
+		}
+	}
+	if unquoted, err := strconv.Unquote(a.Value); err == nil {
+		a.Value = unquoted
+	}
+	if a.Op == "~=" {
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return a, errors.New("invalid regular expression " + a.Value)
+		}
+	}
+	return a, nil
+}
+
+// Checks an assertion read from a request file, which may have been edited by
+// hand
+func (a Assertion) validate() error {
+	if a.Select == "" {
+		return errors.New("assertion must select something")
+	}
+	if a.Select != "status" && !strings.HasPrefix(a.Select, "/") {
+		if _, err := parsePath(a.Select); err != nil {
+			return err
+		}
+	}
+	switch a.Op {
+	case "", "==", "!=":
+	case "~=":
+		if _, err := regexp.Compile(a.Value); err != nil {
+			return errors.New("invalid regular expression " + a.Value)
+		}
+	default:
+		return errors.New("unknown assertion operator " + a.Op)
+	}
+	return nil
+}
+
+func parseAssertions(raw []string) ([]Assertion, error) {
+	var out []Assertion
+	for _, r := range raw {
+		a, err := ParseAssertion(r)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// Returns nil if the response satisfies the assertion. Every selected value
+// has to satisfy the operator.
+func (a Assertion) check(statusCode int, body []byte) error {
+	var (
+		values []string
+		err    error
+	)
+	if a.Select == "status" {
+		values = []string{strconv.Itoa(statusCode)}
+	} else if values, err = selectFromBody(body, a.Select); err != nil {
+		return err
+	}
+	for _, v := range values {
+		var ok bool
+		switch a.Op {
+		case "":
+			ok = true
+		case "==":
+			ok = v == a.Value
+		case "!=":
+			ok = v != a.Value
+		case "~=":
+			re, err := regexp.Compile(a.Value)
+			if err != nil {
+				return errors.New("invalid regular expression " + a.Value)
+			}
+			ok = re.MatchString(v)
+		default:
+			return errors.New("unknown assertion operator " + a.Op)
+		}
+		if !ok {
+			return fmt.Errorf("got %s", v)
+		}
+	}
+	return nil
+}
+
+// Runs every assertion and returns a description of each that failed
+func checkAssertions(assertions []Assertion, statusCode int, body []byte) []string {
+	var failures []string
+	for _, a := range assertions {
+		if err := a.check(statusCode, body); err != nil {
+			failures = append(failures, a.String()+": "+err.Error())
+		}
+	}
+	return failures
+}
+
+// Evaluates captures against a response. Each capture has to select exactly
+// one value.
+func evalCaptures(captures map[string]string, body []byte) (map[string]string, error) {
+	out := make(map[string]string, len(captures))
+	for name, expr := range captures {
+		values, err := selectFromBody(body, expr)
+		if err != nil {
+			return nil, errors.New("failed to capture " + name + ": " + err.Error())
+		}
+		if len(values) != 1 {
+			return nil, fmt.Errorf("failed to capture %s: %s matched %d values", name, expr, len(values))
+		}
+		out[name] = values[0]
+	}
+	return out, nil
+}
+
+// Applies --assert, --clear-asserts, --capture and --remove-capture to a request.
+// Only assertions given with --assert are checked, so a request with a broken
+// assertion saved by hand can still be edited or have it cleared.
+func checksFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	if ctx.Bool("clear-asserts") {
+		reqinfo.Assert = nil
+	}
+	if ctx.IsSet("assert") {
+		assertions, err := parseAssertions(ctx.StringSlice("assert"))
+		if err != nil {
+			return err
+		}
+		reqinfo.Assert = assertions
+	}
+	captures, err := parseVars(ctx.StringSlice("capture"))
+	if err != nil {
+		return err
+	}
+	for name, expr := range captures {
+		if !strings.HasPrefix(expr, "/") {
+			if _, err := parsePath(expr); err != nil {
+				return err
+			}
+		}
+		if reqinfo.Capture == nil {
+			reqinfo.Capture = make(map[string]string)
+		}
+		reqinfo.Capture[name] = expr
+	}
+	for _, name := range ctx.StringSlice("remove-capture") {
+		delete(reqinfo.Capture, name)
+	}
+	if len(reqinfo.Capture) == 0 {
+		reqinfo.Capture = nil
+	}
+	return nil
+}
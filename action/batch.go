@@ -0,0 +1,137 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// A call of a request, along with what it produced
+type callRun struct {
+	name string
+	// printed before every line of output when calling several requests
+	label string
+	// variables of a --data row, which override every other variable
+	row map[string]string
+
+	status   int
+	captured map[string]string
+	err      error
+}
+
+// Writes every line it is given with a prefix such as [Login]. Lines are
+// written whole so the output of concurrent calls does not mix within a line.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	// shared by every writer to the same destination
+	mu  *sync.Mutex
+	buf []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		p.mu.Lock()
+		p.w.Write(append([]byte(p.prefix), p.buf[:i+1]...))
+		p.mu.Unlock()
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Writes what is left of an unfinished line
+func (p *prefixWriter) flush() {
+	if len(p.buf) > 0 {
+		p.Write([]byte{'\n'})
+	}
+}
+
+// Makes every run with at most --parallel of them at once, starting no more
+// often than --rate allows. Output is printed one run at a time in order, or
+// as it arrives with --interleave, and every line is prefixed with the run's
+// label. Returns the number of runs that failed.
+func callBatch(cfgPath string, ctx *cli.Context, httpClient http.Client, app string, runs []*callRun) (int, error) {
+	if ctx.IsSet("output-file") || ctx.Bool("remote-name") || ctx.Bool("continue") || ctx.Bool("interactive") {
+		return 0, errors.New("--output-file, --remote-name, --continue and --interactive can only be used when calling a single request")
+	}
+	parallel := ctx.Int("parallel")
+	if parallel < 1 {
+		return 0, errors.New("parallel must be at least 1")
+	}
+	var (
+		interval time.Duration
+		err      error
+	)
+	if ctx.String("rate") != "" {
+		if interval, err = parseRate(ctx.String("rate")); err != nil {
+			return 0, err
+		}
+	}
+	interleave := ctx.Bool("interleave")
+	var (
+		stdoutMu, stderrMu sync.Mutex
+		sem                = make(chan struct{}, parallel)
+		done               = make([]chan struct{}, len(runs))
+		outputs            = make([]struct{ stdout, stderr bytes.Buffer }, len(runs))
+	)
+	for i := range runs {
+		done[i] = make(chan struct{})
+	}
+	// runs are started in order so --rate spaces them out evenly
+	go func() {
+		var tick <-chan time.Time
+		if interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for i, run := range runs {
+			if tick != nil && i > 0 {
+				<-tick
+			}
+			sem <- struct{}{}
+			stdout := &prefixWriter{w: os.Stdout, prefix: "[" + run.label + "] ", mu: &stdoutMu}
+			stderr := &prefixWriter{w: os.Stderr, prefix: "[" + run.label + "] ", mu: &stderrMu}
+			if !interleave {
+				// each run has buffers of its own, printed once it is its turn
+				stdout.w, stdout.mu = &outputs[i].stdout, new(sync.Mutex)
+				stderr.w, stderr.mu = &outputs[i].stderr, new(sync.Mutex)
+			}
+			go func(i int, run *callRun) {
+				defer close(done[i])
+				defer func() { <-sem }()
+				run.err = callOne(cfgPath, ctx, httpClient, app, run, stdout, stderr)
+				stdout.flush()
+				if run.err != nil && run.err.Error() != "" {
+					fmt.Fprintln(stderr, "error: "+run.err.Error())
+				}
+				stderr.flush()
+			}(i, run)
+		}
+	}()
+	failed := 0
+	for i, run := range runs {
+		<-done[i]
+		if !interleave {
+			os.Stdout.Write(outputs[i].stdout.Bytes())
+			os.Stderr.Write(outputs[i].stderr.Bytes())
+			outputs[i].stdout, outputs[i].stderr = bytes.Buffer{}, bytes.Buffer{}
+		}
+		if run.err != nil {
+			failed++
+		}
+	}
+	return failed, nil
+}
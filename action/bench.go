@@ -0,0 +1,247 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// number of requests sent when neither --requests nor --duration is set
+	defaultBenchRequests = 200
+	// number of histogram buckets and the width of the longest bar
+	benchBuckets  = 10
+	benchBarWidth = 40
+)
+
+// Parses a rate such as 200/s, 30/m or 5/500ms into the interval between
+// requests. A bare number is per second.
+func parseRate(rate string) (time.Duration, error) {
+	count, unit, found := strings.Cut(rate, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, errors.New("rate must be a number of requests per unit of time such as 200/s")
+	}
+	per := time.Second
+	if found {
+		switch unit {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			per, err = time.ParseDuration(unit)
+			if err != nil || per <= 0 {
+				return 0, errors.New("rate must be a number of requests per unit of time such as 200/s")
+			}
+		}
+	}
+	return time.Duration(float64(per) / n), nil
+}
+
+// The outcome of a single request
+type benchResult struct {
+	latency time.Duration
+	status  int
+	err     error
+}
+
+// Options of a benchmark. The benchmark ends after requests requests or once
+// duration passed, whichever comes first; zero means no limit.
+type benchOptions struct {
+	concurrency int
+	requests    int
+	duration    time.Duration
+	// time between the start of two requests, or 0 to send them back to back
+	interval time.Duration
+}
+
+// Sends requests built by newReq until the options say to stop or c is
+// cancelled, and returns the results in the order they finished along with
+// the time it took
+func runBench(c context.Context, client *http.Client, newReq func() (*http.Request, error), opts benchOptions) ([]benchResult, time.Duration, error) {
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, opts.duration)
+		defer cancel()
+	}
+	var (
+		jobs    = make(chan struct{})
+		results []benchResult
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failure error
+	)
+	start := time.Now()
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				req, err := newReq()
+				if err != nil {
+					mu.Lock()
+					failure = err
+					mu.Unlock()
+					continue
+				}
+				t := time.Now()
+				resp, err := client.Do(req.WithContext(c))
+				result := benchResult{err: err}
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+					result.status = resp.StatusCode
+				}
+				result.latency = time.Since(t)
+				// requests cut short by the end of the benchmark are not counted
+				if err != nil && c.Err() != nil {
+					continue
+				}
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	var tick <-chan time.Time
+	if opts.interval > 0 {
+		ticker := time.NewTicker(opts.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+feed:
+	for sent := 0; opts.requests <= 0 || sent < opts.requests; sent++ {
+		if tick != nil && sent > 0 {
+			select {
+			case <-tick:
+			case <-c.Done():
+				break feed
+			}
+		}
+		select {
+		case jobs <- struct{}{}:
+		case <-c.Done():
+			break feed
+		}
+		mu.Lock()
+		stop := failure != nil
+		mu.Unlock()
+		if stop {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return results, time.Since(start), failure
+}
+
+// Returns the latency below which the fraction p of sorted latencies fall
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
+
+// Writes throughput, responses by status, errors, latency percentiles and a
+// latency histogram. Responses with a status of 400 or more count as errors.
+func printBenchReport(w io.Writer, results []benchResult, elapsed time.Duration) {
+	var (
+		latencies []time.Duration
+		statuses  = make(map[int]int)
+		failures  = make(map[string]int)
+		errCount  int
+	)
+	for _, r := range results {
+		if r.err != nil {
+			failures[r.err.Error()]++
+			errCount++
+			continue
+		}
+		latencies = append(latencies, r.latency)
+		statuses[r.status]++
+		if r.status >= 400 {
+			errCount++
+		}
+	}
+	throughput := 0.0
+	if elapsed > 0 {
+		throughput = float64(len(results)) / elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "Requests:\t%d in %s (%.1f/s)\n", len(results), roundLatency(elapsed), throughput)
+	fmt.Fprintf(w, "Errors:\t\t%d\n", errCount)
+	if len(statuses) > 0 {
+		fmt.Fprintln(w, "Status codes:")
+		codes := make([]int, 0, len(statuses))
+		for code := range statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "\t%d %s\t%d\n", code, http.StatusText(code), statuses[code])
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintln(w, "Failed requests:")
+		messages := make([]string, 0, len(failures))
+		for m := range failures {
+			messages = append(messages, m)
+		}
+		sort.Strings(messages)
+		for _, m := range messages {
+			fmt.Fprintf(w, "\t%s\t%d\n", m, failures[m])
+		}
+	}
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Fprintln(w, "Latency:")
+	for _, p := range []struct {
+		name string
+		p    float64
+	}{{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}, {"max", 1}} {
+		fmt.Fprintf(w, "\t%s\t%s\n", p.name, roundLatency(percentile(latencies, p.p)))
+	}
+	fmt.Fprintln(w, "Histogram:")
+	low, high := latencies[0], latencies[len(latencies)-1]
+	width := (high - low) / benchBuckets
+	if width <= 0 {
+		fmt.Fprintf(w, "\t%s\t%d\t%s\n", roundLatency(low), len(latencies), strings.Repeat("#", benchBarWidth))
+		return
+	}
+	var counts [benchBuckets]int
+	for _, l := range latencies {
+		counts[min(int((l-low)/width), benchBuckets-1)]++
+	}
+	most := 0
+	for _, n := range counts {
+		most = max(most, n)
+	}
+	for i, n := range counts {
+		// buckets are labelled with their upper bound
+		bound := low + time.Duration(i+1)*width
+		if i == benchBuckets-1 {
+			bound = high
+		}
+		fmt.Fprintf(w, "\t%s\t%d\t%s\n", roundLatency(bound), n, strings.Repeat("#", n*benchBarWidth/most))
+	}
+}
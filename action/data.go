@@ -0,0 +1,163 @@
+package action
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Reads the rows of a --data file. JSON files hold an array of objects, any
+// other file is read as CSV with the column names in its first line.
+func readDataRows(file string) ([]map[string]string, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.New("failed to read data file " + file)
+	}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		return jsonRows(contents)
+	}
+	return csvRows(contents)
+}
+
+func csvRows(contents []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(contents)).ReadAll()
+	if err != nil {
+		return nil, errors.New("data file is not valid CSV: " + err.Error())
+	}
+	if len(records) == 0 {
+		return nil, errors.New("data file does not have a header line")
+	}
+	header := records[0]
+	for _, name := range header {
+		if strings.TrimSpace(name) == "" {
+			return nil, errors.New("data file has a column without a name")
+		}
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Strings are bound as they are, null as an empty string and anything else
+// as JSON
+func jsonRows(contents []byte) ([]map[string]string, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &objects); err != nil {
+		return nil, errors.New("data file must be a JSON array of objects")
+	}
+	rows := make([]map[string]string, 0, len(objects))
+	for _, object := range objects {
+		row := make(map[string]string, len(object))
+		for name, raw := range object {
+			var s string
+			switch {
+			case json.Unmarshal(raw, &s) == nil:
+				row[name] = s
+			case string(raw) == "null":
+				row[name] = ""
+			default:
+				row[name] = string(raw)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// The outcome of a --data row as written to a JSON results file
+type dataResult struct {
+	Row      int               `json:"row"`
+	Status   int               `json:"status"`
+	Error    string            `json:"error,omitempty"`
+	Captured map[string]string `json:"captured,omitempty"`
+}
+
+// Writes the row number, status, error and captured values of every run.
+// JSON files get an array of objects, any other file gets CSV with a column
+// for every captured variable.
+func writeResults(file string, runs []*callRun) error {
+	results := make([]dataResult, len(runs))
+	names := make(map[string]bool)
+	for i, run := range runs {
+		results[i] = dataResult{Row: i + 1, Status: run.status, Captured: run.captured}
+		if run.err != nil {
+			results[i].Error = run.err.Error()
+		}
+		for name := range run.captured {
+			names[name] = true
+		}
+	}
+	var out bytes.Buffer
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return errors.New("failed to encode results")
+		}
+		out.Write(append(b, '\n'))
+	} else {
+		captures := make([]string, 0, len(names))
+		for name := range names {
+			captures = append(captures, name)
+		}
+		sort.Strings(captures)
+		w := csv.NewWriter(&out)
+		w.Write(append([]string{"row", "status", "error"}, captures...))
+		for _, r := range results {
+			record := []string{strconv.Itoa(r.Row), strconv.Itoa(r.Status), r.Error}
+			for _, name := range captures {
+				record = append(record, r.Captured[name])
+			}
+			w.Write(record)
+		}
+		w.Flush()
+	}
+	if err := os.WriteFile(file, out.Bytes(), 0600); err != nil {
+		return errors.New("failed to write results to " + file)
+	}
+	return nil
+}
+
+// Calls a request once for every row of the --data file, binding the row's
+// columns as template variables
+func callData(cfgPath string, ctx *cli.Context, httpClient http.Client, app, name string) error {
+	rows, err := readDataRows(ctx.String("data"))
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return errors.New("data file does not have any rows")
+	}
+	runs := make([]*callRun, len(rows))
+	for i, row := range rows {
+		runs[i] = &callRun{name: name, label: "row " + strconv.Itoa(i+1), row: row}
+	}
+	failed, err := callBatch(cfgPath, ctx, httpClient, app, runs)
+	if err != nil {
+		return err
+	}
+	if ctx.String("results") != "" {
+		if err := writeResults(ctx.String("results"), runs); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", failed, len(runs))
+	}
+	return nil
+}
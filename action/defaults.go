@@ -0,0 +1,223 @@
+package action
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Settings an app applies to every request it holds. A request overrides a
+// default header or query parameter by setting one with the same name, and
+// removes it by listing its name in unset_headers or unset_query.
+type RequestDefaults struct {
+	Headers []Header `yaml:"headers,omitempty"`
+	Query   Query    `yaml:"query,omitempty"`
+	Timeout string   `yaml:"timeout,omitempty"`
+	Auth    Auth     `yaml:"auth,omitempty"`
+}
+
+// Credentials sent as the Authorization header of every request. Values may
+// use template variables.
+type Auth struct {
+	Type     string `yaml:"type,omitempty"` // basic or bearer
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Token    string `yaml:"token,omitempty"`
+}
+
+// The Authorization header auth stands for, if any. Basic credentials are
+// rendered with vars here since they have to be encoded, a bearer token is
+// rendered along with the other headers.
+func (a Auth) header(vars map[string]string) (*Header, error) {
+	switch a.Type {
+	case "basic":
+		username, err := render(a.Username, vars)
+		if err != nil {
+			return nil, err
+		}
+		password, err := render(a.Password, vars)
+		if err != nil {
+			return nil, err
+		}
+		return &Header{Name: "Authorization", Value: "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))}, nil
+	case "bearer":
+		return &Header{Name: "Authorization", Value: "Bearer " + a.Token}, nil
+	}
+	return nil, nil
+}
+
+// Applies --default-header, --remove-default-header, --default-query,
+// --remove-default-query, --default-timeout, --auth-basic, --auth-bearer and
+// --clear-auth
+func defaultsFromFlags(ctx *cli.Context, defaults RequestDefaults) (RequestDefaults, error) {
+	for _, name := range ctx.StringSlice("remove-default-header") {
+		defaults.Headers = removeHeader(defaults.Headers, name)
+	}
+	for _, s := range ctx.StringSlice("default-header") {
+		h, err := ParseHeader(s)
+		if err != nil {
+			return defaults, err
+		}
+		defaults.Headers = setHeader(defaults.Headers, h)
+	}
+	if names := ctx.StringSlice("remove-default-query"); len(names) > 0 {
+		defaults.Query = mergeQuery(defaults.Query, nil)
+		for _, name := range names {
+			delete(defaults.Query, name)
+		}
+	}
+	query, err := parseKeyValues(ctx.StringSlice("default-query"))
+	if err != nil {
+		return defaults, err
+	}
+	defaults.Query = mergeQuery(defaults.Query, query)
+	if len(defaults.Query) == 0 {
+		defaults.Query = nil
+	}
+	if ctx.IsSet("default-timeout") {
+		if err := validDuration("default timeout", ctx.String("default-timeout")); err != nil {
+			return defaults, err
+		}
+		defaults.Timeout = ctx.String("default-timeout")
+	}
+	if ctx.IsSet("auth-basic") && ctx.IsSet("auth-bearer") {
+		return defaults, errors.New("--auth-basic and --auth-bearer cannot be used together")
+	}
+	if ctx.Bool("clear-auth") {
+		defaults.Auth = Auth{}
+	}
+	if ctx.IsSet("auth-basic") {
+		username, password, found := strings.Cut(ctx.String("auth-basic"), ":")
+		if !found || username == "" {
+			return defaults, errors.New("basic auth must be in the form username:password")
+		}
+		defaults.Auth = Auth{Type: "basic", Username: username, Password: password}
+	}
+	if ctx.IsSet("auth-bearer") {
+		if ctx.String("auth-bearer") == "" {
+			return defaults, errors.New("bearer token must not be empty")
+		}
+		defaults.Auth = Auth{Type: "bearer", Token: ctx.String("auth-bearer")}
+	}
+	return defaults, nil
+}
+
+// Applies --unset-header, --inherit-header, --unset-query and --inherit-query
+func unsetFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	for _, name := range ctx.StringSlice("unset-header") {
+		if !validHeaderName(strings.TrimSpace(name)) {
+			return errors.New("invalid header name " + name)
+		}
+		reqinfo.UnsetHeaders = addName(reqinfo.UnsetHeaders, strings.TrimSpace(name))
+	}
+	for _, name := range ctx.StringSlice("inherit-header") {
+		reqinfo.UnsetHeaders = removeName(reqinfo.UnsetHeaders, strings.TrimSpace(name))
+	}
+	for _, name := range ctx.StringSlice("unset-query") {
+		reqinfo.UnsetQuery = addName(reqinfo.UnsetQuery, name)
+	}
+	for _, name := range ctx.StringSlice("inherit-query") {
+		reqinfo.UnsetQuery = removeName(reqinfo.UnsetQuery, name)
+	}
+	return nil
+}
+
+func addName(names []string, name string) []string {
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+func removeName(names []string, name string) []string {
+	out := names[:0]
+	for _, existing := range names {
+		if existing != name {
+			out = append(out, existing)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// Returns a copy of reqinfo with the app's defaults and the headers of its
+// groups merged in. Inherited headers come first, except those the request
+// sets or unsets itself, header names being compared without regard to case.
+// A group's headers replace those of the app and outer groups with the same
+// name. The request's timeout wins over the default one.
+func resolveRequest(appinfo *AppInfo, groups []*GroupInfo, reqinfo *RequestInfo, vars map[string]string) (*RequestInfo, error) {
+	resolved := *reqinfo
+	defaults := appinfo.Defaults
+	inherited := append([]Header{}, defaults.Headers...)
+	auth, err := defaults.Auth.header(vars)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		inherited = append(removeHeader(inherited, auth.Name), *auth)
+	}
+	for _, g := range groups {
+		for _, h := range g.Headers {
+			inherited = removeHeader(inherited, h.Name)
+		}
+		inherited = append(inherited, g.Headers...)
+	}
+	resolved.Headers = nil
+	for _, h := range inherited {
+		if !hasName(reqinfo.UnsetHeaders, h.Name) && !headerNamed(reqinfo.Headers, h.Name) {
+			resolved.Headers = append(resolved.Headers, h)
+		}
+	}
+	resolved.Headers = append(resolved.Headers, reqinfo.Headers...)
+	resolved.Query = make(Query, len(defaults.Query)+len(reqinfo.Query))
+	for k, v := range defaults.Query {
+		if !hasQueryName(reqinfo.UnsetQuery, k) {
+			resolved.Query[k] = v
+		}
+	}
+	for k, v := range reqinfo.Query {
+		resolved.Query[k] = v
+	}
+	if len(resolved.Query) == 0 {
+		resolved.Query = nil
+	}
+	if resolved.Timeout == "" {
+		resolved.Timeout = defaults.Timeout
+	}
+	resolved.UnsetHeaders, resolved.UnsetQuery = nil, nil
+	return &resolved, nil
+}
+
+func headerNamed(headers []Header, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query parameter names are case sensitive
+func hasQueryName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Largest table diffLines builds, about 16MB
+const maxDiffCells = 4 << 20
+
+// One step in a path into a JSON document, e.g. .items[0] is the key "items"
+// followed by the index 0. Wildcards match any key or index.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.isIndex && s.wildcard:
+		return "[]"
+	case s.isIndex:
+		return "[" + strconv.Itoa(s.index) + "]"
+	case s.wildcard:
+		return ".*"
+	case s.key == "" || strings.ContainsAny(s.key, `.[]" `):
+		return "[" + strconv.Quote(s.key) + "]"
+	}
+	return "." + s.key
+}
+
+func formatPath(p []pathSegment) string {
+	if len(p) == 0 {
+		return "."
+	}
+	var b strings.Builder
+	for _, s := range p {
+		b.WriteString(s.String())
+	}
+	return b.String()
+}
+
+// Parses paths such as .data.items[0].id, .items[].updatedAt, .meta.* or
+// .["key with spaces"]. A lone "." refers to the whole document.
+func parsePath(expr string) ([]pathSegment, error) {
+	invalid := errors.New("invalid path " + expr)
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, ".") && !strings.HasPrefix(expr, "[") {
+		return nil, invalid
+	}
+	var segments []pathSegment
+	for i := 0; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			i++
+			if i >= len(expr) {
+				if len(segments) == 0 {
+					return segments, nil
+				}
+				return nil, invalid
+			}
+			if expr[i] == '[' {
+				continue
+			}
+			end := i
+			for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+				end++
+			}
+			key := expr[i:end]
+			if key == "" {
+				return nil, invalid
+			}
+			segments = append(segments, pathSegment{key: key, wildcard: key == "*"})
+			i = end
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if expr[i+1:min(len(expr), i+2)] == `"` {
+				// quoted keys may contain ']'
+				key, rest, err := unquotePrefix(expr[i+1:])
+				if err != nil || !strings.HasPrefix(rest, "]") {
+					return nil, invalid
+				}
+				segments = append(segments, pathSegment{key: key})
+				i = len(expr) - len(rest) + 1
+				continue
+			}
+			if end < 0 {
+				return nil, invalid
+			}
+			inner := strings.TrimSpace(expr[i+1 : i+end])
+			if inner == "" || inner == "*" {
+				segments = append(segments, pathSegment{isIndex: true, wildcard: true})
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, invalid
+				}
+				segments = append(segments, pathSegment{isIndex: true, index: n})
+			}
+			i += end + 1
+		default:
+			return nil, invalid
+		}
+	}
+	return segments, nil
+}
+
+// Unquotes the Go/JSON string literal at the start of s and returns the rest
+func unquotePrefix(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '"' {
+			unquoted, err := strconv.Unquote(s[:i+1])
+			return unquoted, s[i+1:], err
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
+
+// TRUE if pattern matches path or one of its parents
+func pathHasPrefix(path, pattern []pathSegment) bool {
+	if len(pattern) > len(path) {
+		return false
+	}
+	for i, p := range pattern {
+		s := path[i]
+		if p.isIndex != s.isIndex {
+			return false
+		}
+		if p.wildcard {
+			continue
+		}
+		if p.isIndex && p.index != s.index || !p.isIndex && p.key != s.key {
+			return false
+		}
+	}
+	return true
+}
+
+func parsePaths(exprs []string) ([][]pathSegment, error) {
+	var out [][]pathSegment
+	for _, expr := range exprs {
+		p, err := parsePath(expr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func jsonString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// Compares two decoded JSON values and reports every difference outside of
+// the ignored paths.
+func diffJSON(path []pathSegment, a, b any, ignore [][]pathSegment) []string {
+	for _, pattern := range ignore {
+		if pathHasPrefix(path, pattern) {
+			return nil
+		}
+	}
+	child := func(s pathSegment) []pathSegment {
+		return append(append([]pathSegment{}, path...), s)
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var out []string
+		for _, k := range keys {
+			p := child(pathSegment{key: k})
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inB:
+				out = append(out, diffRemoved(p, x, ignore)...)
+			case !inA:
+				out = append(out, diffAdded(p, y, ignore)...)
+			default:
+				out = append(out, diffJSON(p, x, y, ignore)...)
+			}
+		}
+		return out
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			break
+		}
+		var out []string
+		for i := 0; i < max(len(av), len(bv)); i++ {
+			p := child(pathSegment{isIndex: true, index: i})
+			switch {
+			case i >= len(bv):
+				out = append(out, diffRemoved(p, av[i], ignore)...)
+			case i >= len(av):
+				out = append(out, diffAdded(p, bv[i], ignore)...)
+			default:
+				out = append(out, diffJSON(p, av[i], bv[i], ignore)...)
+			}
+		}
+		return out
+	}
+	if jsonString(a) == jsonString(b) {
+		return nil
+	}
+	return []string{"~ " + formatPath(path) + ": " + jsonString(a) + " -> " + jsonString(b)}
+}
+
+func diffAdded(path []pathSegment, v any, ignore [][]pathSegment) []string {
+	for _, pattern := range ignore {
+		if pathHasPrefix(path, pattern) {
+			return nil
+		}
+	}
+	return []string{"+ " + formatPath(path) + ": " + jsonString(v)}
+}
+
+func diffRemoved(path []pathSegment, v any, ignore [][]pathSegment) []string {
+	for _, pattern := range ignore {
+		if pathHasPrefix(path, pattern) {
+			return nil
+		}
+	}
+	return []string{"- " + formatPath(path) + ": " + jsonString(v)}
+}
+
+// Line based diff using the longest common subsequence of both texts. Lines
+// both texts start or end with are skipped, and when what is left is too large
+// for the table, every remaining line is reported as removed then added.
+func diffLines(a, b string) []string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		x, y = x[1:], y[1:]
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+	var out []string
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			out = append(out, "- "+line)
+		}
+		for _, line := range y {
+			out = append(out, "+ "+line)
+		}
+		return out
+	}
+	lcs := make([][]int32, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+x[i])
+			i++
+		default:
+			out = append(out, "+ "+y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, "- "+x[i])
+	}
+	for ; j < len(y); j++ {
+		out = append(out, "+ "+y[j])
+	}
+	return out
+}
+
+func decodeJSON(body string) (any, bool) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+// Compares two responses. JSON bodies are compared structurally and paths in
+// ignore are skipped, other bodies are compared line by line.
+func diffResponses(oldStatus, newStatus int, oldBody, newBody string, ignore []string) ([]string, error) {
+	patterns, err := parsePaths(ignore)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	if oldStatus != newStatus {
+		out = append(out, fmt.Sprintf("~ status: %d -> %d", oldStatus, newStatus))
+	}
+	a, aok := decodeJSON(oldBody)
+	b, bok := decodeJSON(newBody)
+	if aok && bok {
+		return append(out, diffJSON(nil, a, b, patterns)...), nil
+	}
+	if oldBody != newBody {
+		out = append(out, diffLines(oldBody, newBody)...)
+	}
+	return out, nil
+}
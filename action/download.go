@@ -0,0 +1,184 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/urfave/cli/v2"
+)
+
+// how often the progress line is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// Returns the file the response body should be saved to, or "" to print it.
+// --remote-name uses the last segment of the request URL's path.
+func outputPath(ctx *cli.Context, req *http.Request) (string, error) {
+	if ctx.String("output-file") != "" && ctx.Bool("remote-name") {
+		return "", errors.New("--output-file and --remote-name cannot be used together")
+	}
+	if ctx.String("output-file") != "" {
+		return ctx.String("output-file"), nil
+	}
+	if !ctx.Bool("remote-name") {
+		if ctx.Bool("continue") {
+			return "", errors.New("--continue requires --output-file or --remote-name")
+		}
+		return "", nil
+	}
+	name := path.Base(req.URL.Path)
+	if name == "/" || name == "." || name == "" {
+		return "", errors.New("request URL does not end in a file name, use --output-file instead")
+	}
+	return name, nil
+}
+
+// Asks the server for the rest of a partially downloaded file. Returns the
+// number of bytes already on disk.
+func requestResume(req *http.Request, file string) int64 {
+	stat, err := os.Stat(file)
+	if err != nil || stat.Size() == 0 {
+		return 0
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(stat.Size(), 10)+"-")
+	return stat.Size()
+}
+
+// Streams the response body to file. When offset is set the body is appended
+// if the server honoured the Range request, and the file is rewritten
+// otherwise. Error responses never replace a partial download, nor any file
+// when fail is set. The first maxHistoryBody bytes are returned for the history.
+func saveBody(resp *http.Response, file string, offset int64, fail bool) ([]byte, int64, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// nothing left to download
+		return nil, offset, nil
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		flags = os.O_WRONLY | os.O_APPEND
+	case (offset > 0 || fail) && resp.StatusCode >= 400:
+		// keep the file instead of replacing it with an error page
+		return nil, 0, errors.New("server responded with " + resp.Status + ", " + file + " was not changed")
+	default:
+		offset = 0
+	}
+	f, err := os.OpenFile(file, flags, 0644)
+	if err != nil {
+		return nil, 0, errors.New("failed to open " + file)
+	}
+	defer f.Close()
+	head := &limitedBuffer{limit: maxHistoryBody}
+	var w io.Writer = f
+	if isTerminal(os.Stderr) {
+		p := &progress{done: offset, total: -1, start: time.Now()}
+		if resp.ContentLength >= 0 {
+			p.total = offset + resp.ContentLength
+		}
+		defer p.finish()
+		w = io.MultiWriter(f, p)
+	}
+	n, err := io.Copy(io.MultiWriter(w, head), resp.Body)
+	if err != nil {
+		return nil, 0, errors.New("failed to save response body to " + file)
+	}
+	return head.Bytes(), offset + n, nil
+}
+
+// Keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+	// number of bytes written, including discarded ones
+	total int64
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// Draws a single progress line on stderr while a download is written through it
+type progress struct {
+	done, total int64
+	start, last time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if time.Since(p.last) >= progressInterval {
+		p.draw()
+	}
+	return len(b), nil
+}
+
+func (p *progress) draw() {
+	p.last = time.Now()
+	line := formatBytes(p.done)
+	if p.total > 0 {
+		line += " / " + formatBytes(p.total) + fmt.Sprintf(" %3d%%", p.done*100/p.total)
+	}
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		line += "  " + formatBytes(int64(float64(p.done)/elapsed)) + "/s"
+	}
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s", line)
+}
+
+func (p *progress) finish() {
+	p.draw()
+	fmt.Fprintln(os.Stderr)
+}
+
+// Formats a byte count using binary units, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// TRUE if the body should not be written to a terminal. Text content types are
+// always printable, anything else is checked for NUL bytes and invalid UTF-8.
+func isBinary(body []byte, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if strings.HasPrefix(mediaType, "text/") || bodyFormat(contentType) != "" {
+			return false
+		}
+	}
+	sample := body[:min(len(body), 512)]
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	// the sample may end in the middle of a multi-byte character
+	if len(sample) < len(body) {
+		for i := 1; i < utf8.UTFMax && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	return !utf8.Valid(sample)
+}
+
+// Refuses to dump binary bodies to a terminal
+func checkPrintable(body []byte, contentType string) error {
+	if isTerminal(os.Stdout) && isBinary(body, contentType) {
+		return errors.New("response body is binary, use --output-file to save it")
+	}
+	return nil
+}
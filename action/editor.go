@@ -0,0 +1,142 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const editorHelp = `# Edit the request below. Comments are ignored.
+# Save an empty file to abort.
+`
+
+// Returns the user's preferred editor, falling back to vi like git does
+func editorCommand() string {
+	for _, env := range []string{"SP9RK_EDITOR", "VISUAL", "EDITOR"} {
+		if e := os.Getenv(env); e != "" {
+			return e
+		}
+	}
+	return "vi"
+}
+
+// Opens file in the user's editor and waits for it to exit. The editor is run
+// through the shell so values like "code --wait" work.
+func runEditor(file string) error {
+	editor := editorCommand()
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, file)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.New("editor " + editor + " exited with an error")
+	}
+	return nil
+}
+
+// Removes the help and error annotations at the top of an edited file. Other
+// comments are left to the YAML decoder, which ignores them, so lines starting
+// with '#' inside a block scalar such as the body are kept.
+func stripComments(contents []byte) []byte {
+	lines := strings.Split(string(contents), "\n")
+	for len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "#") {
+		lines = lines[1:]
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// Parses an edited request, rejecting unknown fields and invalid values
+func parseEditedRequest(contents []byte, name string) (*RequestInfo, error) {
+	reqinfo := new(RequestInfo)
+	dec := yaml.NewDecoder(bytes.NewReader(contents))
+	dec.KnownFields(true)
+	if err := dec.Decode(reqinfo); err != nil {
+		return nil, errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if reqinfo.Name != name {
+		return nil, errors.New("name cannot be changed while editing (expected " + name + ")")
+	}
+	if reqinfo.Method == "" {
+		return nil, errors.New("method must not be empty")
+	}
+	if !validHeaderName(reqinfo.Method) {
+		return nil, errors.New("method " + reqinfo.Method + " is invalid")
+	}
+	if !requestKinds[reqinfo.Kind] {
+		return nil, errors.New("unsupported request kind " + reqinfo.Kind)
+	}
+	for name := range reqinfo.Query {
+		if name == "" {
+			return nil, errors.New("query parameters must have a name")
+		}
+	}
+	if err := validHooks(reqinfo); err != nil {
+		return nil, err
+	}
+	if err := validGraphQL(reqinfo); err != nil {
+		return nil, err
+	}
+	if reqinfo.Path == "" {
+		reqinfo.Path = "/"
+	}
+	if err := validDuration("timeout", reqinfo.Timeout); err != nil {
+		return nil, err
+	}
+	if err := validDuration("retry delay", reqinfo.RetryDelay); err != nil {
+		return nil, err
+	}
+	if reqinfo.Retries < 0 {
+		return nil, errors.New("retries must not be negative")
+	}
+	if err := validRetryOn(reqinfo.RetryOn); err != nil {
+		return nil, err
+	}
+	for _, a := range reqinfo.Assert {
+		if err := a.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return reqinfo, nil
+}
+
+// Lets the user edit reqinfo in their editor until it is valid. Returns nil
+// if the user saved an empty file, which aborts the edit.
+func editInteractively(reqinfo *RequestInfo) (*RequestInfo, error) {
+	data, err := yaml.Marshal(reqinfo)
+	if err != nil {
+		return nil, errors.New("failed to marshal data")
+	}
+	f, err := os.CreateTemp("", "sp9rk-"+strings.ReplaceAll(reqinfo.Name, "/", "-")+"-*.yml")
+	if err != nil {
+		return nil, errors.New("failed to create temporary file")
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	annotation := editorHelp
+	for {
+		if err := os.WriteFile(f.Name(), append([]byte(annotation), data...), 0600); err != nil {
+			return nil, err
+		}
+		if err := runEditor(f.Name()); err != nil {
+			return nil, err
+		}
+		contents, err := os.ReadFile(f.Name())
+		if err != nil {
+			return nil, errors.New("failed to read edited request")
+		}
+		data = stripComments(contents)
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, nil
+		}
+		edited, err := parseEditedRequest(data, reqinfo.Name)
+		if err == nil {
+			return edited, nil
+		}
+		annotation = editorHelp + "#\n# ERROR: " + strings.ReplaceAll(err.Error(), "\n", "\n# ") + "\n#\n"
+	}
+}
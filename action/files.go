@@ -3,35 +3,148 @@ package action
 import (
 	"errors"
 	"os"
-	"path"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Query parameters by name. A name may be repeated with several values.
+type Query map[string][]string
+
 type RequestInfo struct {
 	Version     string   `yaml:"version"`
 	Name        string   `yaml:"name"`
 	Description string   `yaml:"description"`
+	Kind        string   `yaml:"kind,omitempty"` // http (the default), websocket, graphql or grpc
 	Method      string   `yaml:"method"`
 	Path        string   `yaml:"path"`
-	Headers     []string `yaml:"headers"`
+	Headers     []Header `yaml:"headers"`
 	Body        string   `yaml:"body"`
+	Query       Query    `yaml:"query,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	RetryOn     []string `yaml:"retry_on,omitempty"`
+	RetryDelay  string   `yaml:"retry_delay,omitempty"`
+	// checks run against every response
+	Assert []Assertion `yaml:"assert,omitempty"`
+	// values saved as variables after every call, by variable name
+	Capture map[string]string `yaml:"capture,omitempty"`
+	// sent as text messages once a websocket is open
+	Messages []string `yaml:"messages,omitempty"`
+	// GraphQL document, either inline or read from a file when calling
+	Document      string `yaml:"document,omitempty"`
+	DocumentFile  string `yaml:"document_file,omitempty"`
+	OperationName string `yaml:"operation_name,omitempty"`
+	// GraphQL variables as a JSON object, rendered as a template
+	Variables string `yaml:"variables,omitempty"`
+	// response served by sp9rk mock
+	Example *Example `yaml:"example,omitempty"`
+	// full name of the gRPC method, e.g. grpc.health.v1.Health/Check. The
+	// message is read from Body as JSON.
+	RPC   string `yaml:"rpc,omitempty"`
+	Hooks Hooks  `yaml:"hooks,omitempty"`
+	// names of the app's default headers and query parameters not to send
+	UnsetHeaders []string `yaml:"unset_headers,omitempty"`
+	UnsetQuery   []string `yaml:"unset_query,omitempty"`
+}
+
+// Writes a file that may hold credentials so only its owner can read it.
+// Files saved by earlier versions with wider permissions are narrowed.
+func writePrivateFile(name string, data []byte) error {
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(name, 0600)
 }
 
 func WriteRequestFiles(cfgPath, app string, req *RequestInfo) error {
+	if err := nameConflict(cfgPath, app, req.Name, false); err != nil {
+		return err
+	}
 	data, err := yaml.Marshal(req)
 	if err != nil {
 		return errors.New("failed to marshal data")
 	}
-	path := path.Join(AppPath(cfgPath, app), req.Name+".yml")
-	return os.WriteFile(path, data, 0700)
+	path := ReqPath(cfgPath, app, req.Name)
+	// requests in groups are saved in a directory of the group
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writePrivateFile(path, data)
+}
+
+// Returns every request saved in an application, including those in groups,
+// sorted by name
+func readRequestFiles(cfgPath, app string) ([]*RequestInfo, error) {
+	nodes, err := readRequestTree(cfgPath, app, "")
+	if err != nil {
+		return nil, err
+	}
+	return flattenRequestTree(nil, nodes), nil
+}
+
+func readAppInfo(cfgPath, app string) (*AppInfo, error) {
+	contents, err := os.ReadFile(AppInfoFilePath(cfgPath, app))
+	if err != nil {
+		return nil, errors.New("failed to retrieve app information")
+	}
+	appinfo := new(AppInfo)
+	if err := yaml.Unmarshal(contents, appinfo); err != nil {
+		return nil, errors.New("appinfo file is malformed or corrupted")
+	}
+	return appinfo, nil
+}
+
+func readRequestInfo(cfgPath, app, req string) (*RequestInfo, error) {
+	contents, err := os.ReadFile(ReqPath(cfgPath, app, req))
+	if err != nil {
+		return nil, errors.New("failed to retrieve request information")
+	}
+	reqinfo := new(RequestInfo)
+	if err := yaml.Unmarshal(contents, reqinfo); err != nil {
+		return nil, errors.New("request file is malformed or corrupted")
+	}
+	return reqinfo, nil
 }
 
 type AppInfo struct {
-	Version     string `yaml:"version"`
-	Name        string `yaml:"name"`
-	Description string `yaml:"description"`
-	Host        string `yaml:"host"`
+	Version     string    `yaml:"version"`
+	Name        string    `yaml:"name"`
+	Description string    `yaml:"description"`
+	Host        string    `yaml:"host"`
+	TLS         TLSInfo   `yaml:"tls,omitempty"`
+	Proxy       ProxyInfo `yaml:"proxy,omitempty"`
+	GRPC        GRPCInfo  `yaml:"grpc,omitempty"`
+	Hooks       Hooks     `yaml:"hooks,omitempty"`
+	// merged into every request of the app
+	Defaults RequestDefaults `yaml:"defaults,omitempty"`
+}
+
+// A canned response for a request
+type Example struct {
+	Status  int      `yaml:"status"`
+	Headers []Header `yaml:"headers,omitempty"`
+	Body    string   `yaml:"body,omitempty"`
+	// how long to wait before responding, e.g. 200ms
+	Latency string `yaml:"latency,omitempty"`
+}
+
+// Where gRPC calls find the descriptors of their methods. Without proto files
+// the server is asked using server reflection.
+type GRPCInfo struct {
+	ProtoFiles  []string `yaml:"proto_files,omitempty"`
+	ImportPaths []string `yaml:"import_paths,omitempty"`
+}
+
+// TLS settings used when calling an application's host. File paths are
+// stored as absolute paths so calls work from any directory.
+type TLSInfo struct {
+	CACert     string `yaml:"ca_cert,omitempty"`
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+	ServerName string `yaml:"server_name,omitempty"`
+	MinVersion string `yaml:"min_version,omitempty"`
+	Insecure   bool   `yaml:"insecure,omitempty"`
 }
 
 func WriteAppFiles(cfgPath string, app *AppInfo) error {
@@ -43,10 +156,53 @@ func WriteAppFiles(cfgPath string, app *AppInfo) error {
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(AppInfoFilePath(cfgPath, app.Name), data, 0700)
+	err = writePrivateFile(AppInfoFilePath(cfgPath, app.Name), data)
 	if err != nil {
 		os.Remove(AppPath(cfgPath, app.Name))
 		return err
 	}
 	return nil
 }
+
+// Proxy used to reach a host. Credentials are stored in the URL's userinfo.
+// Hosts matching an entry in NoProxy are always dialed directly.
+type ProxyInfo struct {
+	URL     string   `yaml:"url,omitempty"`
+	NoProxy []string `yaml:"no_proxy,omitempty"`
+}
+
+// Global settings that apply to every application
+type Config struct {
+	Version string    `yaml:"version"`
+	Proxy   ProxyInfo `yaml:"proxy,omitempty"`
+	// Number of calls kept in the history. 0 uses the default, negative
+	// values disable the history.
+	HistoryLimit int `yaml:"history_limit,omitempty"`
+	// Whether the values of variables and query parameters are saved in the
+	// history instead of being redacted
+	HistoryValues bool `yaml:"history_values,omitempty"`
+}
+
+// Returns the global configuration, or an empty one if none has been saved yet
+func ReadConfig(cfgPath string) (*Config, error) {
+	cfg := &Config{Version: "1"}
+	contents, err := os.ReadFile(ConfigFilePath(cfgPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to read configuration")
+	}
+	if err := yaml.Unmarshal(contents, cfg); err != nil {
+		return nil, errors.New("configuration file is malformed or corrupted")
+	}
+	return cfg, nil
+}
+
+func WriteConfig(cfgPath string, cfg *Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.New("failed to marshal data")
+	}
+	return writePrivateFile(ConfigFilePath(cfgPath), data)
+}
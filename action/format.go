@@ -0,0 +1,371 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"html"
+	"io"
+	"mime"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	colorReset   = "\x1b[0m"
+	colorKey     = "\x1b[34;1m"
+	colorString  = "\x1b[32m"
+	colorNumber  = "\x1b[36m"
+	colorLiteral = "\x1b[35m"
+	colorTag     = "\x1b[34;1m"
+	colorAttr    = "\x1b[36m"
+	colorPunct   = "\x1b[2m"
+)
+
+// elements that never have a closing tag in HTML
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// TRUE if f is connected to a terminal
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// TRUE if output to w should be colorized, which is only when w is a
+// terminal. A non-empty NO_COLOR turns colors off, see https://no-color.org.
+func useColor(ctx *cli.Context, w io.Writer) bool {
+	if ctx.Bool("no-color") || ctx.Bool("raw") {
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// Returns "json", "xml", "html" or "" for content types sp9rk can format
+func bodyFormat(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return "json"
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return "xml"
+	case mediaType == "text/html":
+		return "html"
+	}
+	return ""
+}
+
+// Indents JSON, XML and HTML bodies and optionally colorizes them. Bodies that
+// cannot be parsed as their content type are returned untouched.
+func formatBody(body []byte, contentType string, color bool) []byte {
+	var (
+		out []byte
+		err error
+	)
+	switch bodyFormat(contentType) {
+	case "json":
+		var b bytes.Buffer
+		if err = json.Indent(&b, bytes.TrimSpace(body), "", "  "); err == nil {
+			out = b.Bytes()
+			if color {
+				out = colorizeJSON(out)
+			}
+		}
+	case "xml":
+		out, err = indentMarkup(body, false, color)
+	case "html":
+		out, err = indentMarkup(body, true, color)
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// Writes the body of a response to w, formatted unless --raw is set
+func printBody(ctx *cli.Context, w io.Writer, body []byte, contentType string) {
+	if !ctx.Bool("raw") {
+		body = formatBody(body, contentType, useColor(ctx, w))
+	}
+	w.Write(append(body, '\n'))
+}
+
+func paint(b *bytes.Buffer, color, s string) {
+	b.WriteString(color)
+	b.WriteString(s)
+	b.WriteString(colorReset)
+}
+
+// Colorizes indented JSON. Strings followed by a colon are treated as keys.
+func colorizeJSON(src []byte) []byte {
+	var b bytes.Buffer
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(src))
+			next := end
+			for next < len(src) && src[next] == ' ' {
+				next++
+			}
+			if next < len(src) && src[next] == ':' {
+				paint(&b, colorKey, string(src[i:end]))
+			} else {
+				paint(&b, colorString, string(src[i:end]))
+			}
+			i = end
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(src) && strings.IndexByte("0123456789.eE+-", src[end]) >= 0 {
+				end++
+			}
+			paint(&b, colorNumber, string(src[i:end]))
+			i = end
+		case bytes.HasPrefix(src[i:], []byte("true")):
+			paint(&b, colorLiteral, "true")
+			i += 4
+		case bytes.HasPrefix(src[i:], []byte("false")):
+			paint(&b, colorLiteral, "false")
+			i += 5
+		case bytes.HasPrefix(src[i:], []byte("null")):
+			paint(&b, colorLiteral, "null")
+			i += 4
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.Bytes()
+}
+
+// An element, text, comment, instruction or directive of a parsed document
+type markupNode struct {
+	// set for elements
+	start    *xml.StartElement
+	children []*markupNode
+	// whether the element's end tag was seen
+	closed bool
+	// set for everything else
+	token xml.Token
+}
+
+// Parses XML or HTML into a tree. In HTML, void elements have no children and
+// an end tag closes any element left open inside the one it ends.
+func parseMarkup(body []byte, isHTML bool) (*markupNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	if isHTML {
+		dec.Strict = false
+		dec.AutoClose = xml.HTMLAutoClose
+		dec.Entity = xml.HTMLEntity
+	}
+	sameName := func(a, b xml.Name) bool {
+		if isHTML {
+			return strings.EqualFold(a.Local, b.Local)
+		}
+		return a == b
+	}
+	root := &markupNode{}
+	stack := []*markupNode{root}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			start := t.Copy()
+			n := &markupNode{start: &start}
+			parent.children = append(parent.children, n)
+			if !isHTML || !htmlVoidElements[strings.ToLower(t.Name.Local)] {
+				stack = append(stack, n)
+			}
+		case xml.EndElement:
+			for i := len(stack) - 1; i > 0; i-- {
+				if sameName(stack[i].start.Name, t.Name) {
+					stack[i].closed = true
+					stack = stack[:i]
+					break
+				}
+			}
+		default:
+			parent.children = append(parent.children, &markupNode{token: xml.CopyToken(tok)})
+		}
+	}
+	return root, nil
+}
+
+// Re-indents XML or HTML one element per line. Text is kept on the same line
+// as its element when it is the element's only child. Elements mixing text
+// and other elements, and HTML elements whose whitespace matters such as pre
+// or script, are written exactly as received.
+func indentMarkup(body []byte, isHTML, color bool) ([]byte, error) {
+	root, err := parseMarkup(body, isHTML)
+	if err != nil {
+		return nil, err
+	}
+	escape := func(s string) string {
+		if isHTML {
+			return html.EscapeString(s)
+		}
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	name := func(n xml.Name) string {
+		if n.Space != "" && !isHTML {
+			return n.Space + ":" + n.Local
+		}
+		return n.Local
+	}
+	// HTML elements whose text is written unescaped, and those whose
+	// whitespace is kept
+	rawText := func(n *markupNode) bool {
+		local := strings.ToLower(n.start.Name.Local)
+		return isHTML && (local == "script" || local == "style")
+	}
+	keepSpace := func(n *markupNode) bool {
+		local := strings.ToLower(n.start.Name.Local)
+		return rawText(n) || isHTML && (local == "pre" || local == "textarea")
+	}
+
+	var b bytes.Buffer
+	tag := func(s string) {
+		if color {
+			paint(&b, colorTag, s)
+		} else {
+			b.WriteString(s)
+		}
+	}
+	newline := func(depth int) {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat("  ", depth))
+	}
+	startTag := func(t *xml.StartElement) {
+		tag("<" + name(t.Name))
+		for _, a := range t.Attr {
+			b.WriteByte(' ')
+			if color {
+				paint(&b, colorAttr, name(a.Name))
+				b.WriteString("=")
+				paint(&b, colorString, `"`+escape(a.Value)+`"`)
+			} else {
+				b.WriteString(name(a.Name) + `="` + escape(a.Value) + `"`)
+			}
+		}
+		tag(">")
+	}
+	endTag := func(n *markupNode) {
+		if n.closed {
+			tag("</" + name(n.start.Name) + ">")
+		}
+	}
+	other := func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.Comment:
+			if color {
+				paint(&b, colorPunct, "<!--"+string(t)+"-->")
+			} else {
+				b.WriteString("<!--" + string(t) + "-->")
+			}
+		case xml.ProcInst:
+			b.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+		case xml.Directive:
+			b.WriteString("<!" + string(t) + ">")
+		}
+	}
+
+	// writes n without adding or removing any whitespace
+	var exact func(n *markupNode, raw bool)
+	exact = func(n *markupNode, raw bool) {
+		if n.start == nil {
+			if text, ok := n.token.(xml.CharData); ok {
+				if raw {
+					b.Write(text)
+				} else {
+					b.WriteString(escape(string(text)))
+				}
+				return
+			}
+			other(n.token)
+			return
+		}
+		startTag(n.start)
+		for _, c := range n.children {
+			exact(c, raw || rawText(n))
+		}
+		endTag(n)
+	}
+	var indent func(n *markupNode, depth int)
+	indent = func(n *markupNode, depth int) {
+		if n.start == nil {
+			if text, ok := n.token.(xml.CharData); ok {
+				if s := strings.TrimSpace(string(text)); s != "" {
+					newline(depth)
+					b.WriteString(escape(s))
+				}
+				return
+			}
+			newline(depth)
+			other(n.token)
+			return
+		}
+		newline(depth)
+		startTag(n.start)
+		var text strings.Builder
+		hasText, hasOther := false, false
+		for _, c := range n.children {
+			if t, ok := c.token.(xml.CharData); ok {
+				text.Write(t)
+				hasText = hasText || strings.TrimSpace(string(t)) != ""
+			} else {
+				hasOther = true
+			}
+		}
+		switch {
+		case keepSpace(n) || hasText && hasOther:
+			for _, c := range n.children {
+				exact(c, rawText(n))
+			}
+		case hasText:
+			b.WriteString(escape(strings.TrimSpace(text.String())))
+		case hasOther:
+			for _, c := range n.children {
+				indent(c, depth+1)
+			}
+			if n.closed {
+				newline(depth)
+			}
+		}
+		endTag(n)
+	}
+	for _, n := range root.children {
+		indent(n, 0)
+	}
+	return b.Bytes(), nil
+}
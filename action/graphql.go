@@ -0,0 +1,436 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// how deep generated documents select fields of nested objects
+const graphqlSelectionDepth = 2
+
+// Applies --document, --document-file, --operation and --variables to a request
+func graphqlFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	if ctx.IsSet("document") {
+		reqinfo.Document = ctx.String("document")
+	}
+	if ctx.IsSet("document-file") {
+		reqinfo.DocumentFile = ""
+		if ctx.String("document-file") != "" {
+			abs, err := filepath.Abs(ctx.String("document-file"))
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(abs); err != nil {
+				return errors.New("file " + ctx.String("document-file") + " does not exist")
+			}
+			reqinfo.DocumentFile = abs
+		}
+	}
+	if reqinfo.Document != "" && reqinfo.DocumentFile != "" {
+		return errors.New("a request cannot have both a document and a document file")
+	}
+	if ctx.IsSet("operation") {
+		reqinfo.OperationName = ctx.String("operation")
+	}
+	if ctx.IsSet("variables") {
+		reqinfo.Variables = ctx.String("variables")
+	}
+	return nil
+}
+
+// The JSON envelope GraphQL servers expect, see
+// https://graphql.github.io/graphql-over-http/draft/#sec-Request-Parameters
+type graphqlRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+// Checks the GraphQL fields of a request saved without flags, e.g. after
+// editing it. Variables that use templates outside of strings are only
+// checked when they are rendered.
+func validGraphQL(reqinfo *RequestInfo) error {
+	if reqinfo.Document != "" && reqinfo.DocumentFile != "" {
+		return errors.New("a request cannot have both a document and a document file")
+	}
+	variables := strings.TrimSpace(reqinfo.Variables)
+	if variables != "" && !json.Valid([]byte(variables)) && !strings.Contains(variables, "{{") {
+		return errors.New("graphql variables are not valid JSON")
+	}
+	return nil
+}
+
+// Builds the POST request for a GraphQL request. The document and variables
+// are rendered on their own so the envelope is always valid JSON.
+func newGraphQLRequest(appinfo *AppInfo, reqinfo *RequestInfo, vars map[string]string) (*http.Request, error) {
+	document := reqinfo.Document
+	if reqinfo.DocumentFile != "" {
+		contents, err := os.ReadFile(reqinfo.DocumentFile)
+		if err != nil {
+			return nil, errors.New("failed to read document file " + reqinfo.DocumentFile)
+		}
+		document = string(contents)
+	}
+	if strings.TrimSpace(document) == "" {
+		return nil, errors.New("graphql request does not have a document")
+	}
+	document, err := render(document, vars)
+	if err != nil {
+		return nil, err
+	}
+	envelope := graphqlRequest{Query: document, OperationName: reqinfo.OperationName}
+	if strings.TrimSpace(reqinfo.Variables) != "" {
+		variables, err := renderGraphQLVariables(reqinfo.Variables, vars)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(variables)) {
+			return nil, errors.New("graphql variables are not valid JSON")
+		}
+		envelope.Variables = json.RawMessage(variables)
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, errors.New("failed to encode graphql request")
+	}
+	post := *reqinfo
+	post.Method, post.Body = "POST", ""
+	req, err := newRequest(appinfo, &post, vars)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	req.ContentLength = int64(len(body))
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	return req, nil
+}
+
+// Renders the variables of a GraphQL request. Variables that are JSON before
+// rendering only have their strings rendered, so values with quotes or
+// newlines are escaped. Others, such as {"id": {{.id}}}, are rendered as text.
+func renderGraphQLVariables(text string, vars map[string]string) (string, error) {
+	d := json.NewDecoder(strings.NewReader(text))
+	d.UseNumber()
+	var v any
+	if d.Decode(&v) != nil || d.More() {
+		return render(text, vars)
+	}
+	v, err := renderJSONStrings(v, vars)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.New("failed to encode graphql variables")
+	}
+	return string(b), nil
+}
+
+// Renders every string in a decoded JSON value, including object keys
+func renderJSONStrings(v any, vars map[string]string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return render(v, vars)
+	case []any:
+		for i, e := range v {
+			r, err := renderJSONStrings(e, vars)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = r
+		}
+		return v, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			key, err := render(k, vars)
+			if err != nil {
+				return nil, err
+			}
+			if out[key], err = renderJSONStrings(e, vars); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+type graphqlError struct {
+	Message   string `json:"message"`
+	Path      []any  `json:"path"`
+	Locations []struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	} `json:"locations"`
+}
+
+func (e graphqlError) String() string {
+	s := e.Message
+	if len(e.Path) > 0 {
+		parts := make([]string, len(e.Path))
+		for i, p := range e.Path {
+			parts[i] = fmt.Sprint(p)
+		}
+		s += " (path " + strings.Join(parts, ".") + ")"
+	}
+	for _, l := range e.Locations {
+		s += fmt.Sprintf(" at %d:%d", l.Line, l.Column)
+	}
+	return s
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphqlError  `json:"errors"`
+}
+
+// Prints the data of a GraphQL response to stdout and its errors to stderr.
+// Returns the number of errors. Bodies that are not GraphQL responses are
+// printed as they are.
+func printGraphQL(ctx *cli.Context, stdout, stderr io.Writer, body []byte, contentType string) int {
+	var resp graphqlResponse
+	if ctx.Bool("raw") || json.Unmarshal(body, &resp) != nil || resp.Data == nil && resp.Errors == nil {
+		printBody(ctx, stdout, body, contentType)
+		return 0
+	}
+	if len(resp.Data) > 0 && string(resp.Data) != "null" {
+		printBody(ctx, stdout, resp.Data, "application/json")
+	}
+	for _, e := range resp.Errors {
+		fmt.Fprintln(stderr, "graphql error: "+e.String())
+	}
+	return len(resp.Errors)
+}
+
+// A reference to a type in an introspection result, e.g. [String!]!
+type graphqlTypeRef struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	OfType *graphqlTypeRef `json:"ofType"`
+}
+
+func (t graphqlTypeRef) String() string {
+	switch {
+	case t.Kind == "NON_NULL" && t.OfType != nil:
+		return t.OfType.String() + "!"
+	case t.Kind == "LIST" && t.OfType != nil:
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// The named type underneath any lists and non-null wrappers
+func (t graphqlTypeRef) named() graphqlTypeRef {
+	for t.OfType != nil {
+		t = *t.OfType
+	}
+	return t
+}
+
+type graphqlField struct {
+	Name string `json:"name"`
+	Args []struct {
+		Name string         `json:"name"`
+		Type graphqlTypeRef `json:"type"`
+	} `json:"args"`
+	Type graphqlTypeRef `json:"type"`
+}
+
+type graphqlType struct {
+	Kind   string         `json:"kind"`
+	Name   string         `json:"name"`
+	Fields []graphqlField `json:"fields"`
+}
+
+type graphqlSchema struct {
+	QueryType    *struct{ Name string } `json:"queryType"`
+	MutationType *struct{ Name string } `json:"mutationType"`
+	Types        []graphqlType          `json:"types"`
+}
+
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind
+      name
+      fields {
+        name
+        args { name type { ...TypeRef } }
+        type { ...TypeRef }
+      }
+    }
+  }
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } }
+}`
+
+// Asks a GraphQL server to describe its schema
+func introspect(httpClient http.Client, endpoint string, headers []Header) (*graphqlSchema, error) {
+	body, _ := json.Marshal(graphqlRequest{Query: introspectionQuery, OperationName: "IntrospectionQuery"})
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("failed to create web request")
+	}
+	for _, h := range headers {
+		req.Header.Add(h.Name, h.Value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.New("failed to send web request")
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Data *struct {
+			Schema graphqlSchema `json:"__schema"`
+		} `json:"data"`
+		Errors []graphqlError `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, errors.New("server did not respond with a GraphQL introspection result")
+	}
+	if len(out.Errors) > 0 {
+		return nil, errors.New("introspection failed: " + out.Errors[0].String())
+	}
+	if out.Data == nil || out.Data.Schema.QueryType == nil {
+		return nil, errors.New("server did not respond with a GraphQL introspection result")
+	}
+	return &out.Data.Schema, nil
+}
+
+// Builds the selection set for a field of the given type. Scalars need none,
+// objects select their scalar fields and nested objects up to depth levels.
+func graphqlSelection(types map[string]graphqlType, t graphqlTypeRef, depth int, indent string) string {
+	named, ok := types[t.named().Name]
+	if !ok || named.Kind != "OBJECT" && named.Kind != "INTERFACE" {
+		return ""
+	}
+	var lines []string
+	for _, f := range named.Fields {
+		if len(f.Args) > 0 && hasRequiredArg(f) {
+			continue
+		}
+		fieldType := types[f.Type.named().Name]
+		if fieldType.Kind == "OBJECT" || fieldType.Kind == "INTERFACE" {
+			if depth <= 1 {
+				continue
+			}
+			if sub := graphqlSelection(types, f.Type, depth-1, indent+"  "); sub != "" {
+				lines = append(lines, indent+"  "+f.Name+" "+sub)
+			}
+			continue
+		}
+		if fieldType.Kind == "UNION" {
+			continue
+		}
+		lines = append(lines, indent+"  "+f.Name)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, indent+"  __typename")
+	}
+	return "{\n" + strings.Join(lines, "\n") + "\n" + indent + "}"
+}
+
+func hasRequiredArg(f graphqlField) bool {
+	for _, a := range f.Args {
+		if a.Type.Kind == "NON_NULL" {
+			return true
+		}
+	}
+	return false
+}
+
+// Generates a request for a root field of a query or mutation. Every argument
+// becomes a variable, which starts out as null.
+func graphqlFieldRequest(types map[string]graphqlType, operation string, f graphqlField, endpoint string) *RequestInfo {
+	var (
+		params, args []string
+		variables    = make(map[string]any)
+	)
+	for _, a := range f.Args {
+		params = append(params, "$"+a.Name+": "+a.Type.String())
+		args = append(args, a.Name+": $"+a.Name)
+		variables[a.Name] = nil
+	}
+	doc := operation + " " + f.Name
+	if len(params) > 0 {
+		doc += "(" + strings.Join(params, ", ") + ")"
+	}
+	doc += " {\n  " + f.Name
+	if len(args) > 0 {
+		doc += "(" + strings.Join(args, ", ") + ")"
+	}
+	if sel := graphqlSelection(types, f.Type, graphqlSelectionDepth, "  "); sel != "" {
+		doc += " " + sel
+	}
+	doc += "\n}\n"
+	reqinfo := &RequestInfo{
+		Version:       "1",
+		Name:          f.Name,
+		Description:   operation + " " + f.Name + " returning " + f.Type.String(),
+		Kind:          "graphql",
+		Method:        "POST",
+		Path:          endpoint,
+		Document:      doc,
+		OperationName: f.Name,
+	}
+	if len(variables) > 0 {
+		b, _ := json.MarshalIndent(variables, "", "  ")
+		reqinfo.Variables = string(b)
+	}
+	return reqinfo
+}
+
+// Generates a request for every query and mutation in a schema, sorted by
+// name. A mutation with the same name as a query is named mutation-<field>.
+func graphqlRequests(schema *graphqlSchema, endpoint string) []*RequestInfo {
+	types := make(map[string]graphqlType, len(schema.Types))
+	for _, t := range schema.Types {
+		types[t.Name] = t
+	}
+	var out []*RequestInfo
+	names := make(map[string]bool)
+	roots := []struct {
+		operation string
+		root      *struct{ Name string }
+	}{{"query", schema.QueryType}, {"mutation", schema.MutationType}}
+	for _, r := range roots {
+		if r.root == nil {
+			continue
+		}
+		fields := types[r.root.Name].Fields
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+		for _, f := range fields {
+			reqinfo := graphqlFieldRequest(types, r.operation, f, endpoint)
+			if names[reqinfo.Name] {
+				reqinfo.Name = r.operation + "-" + reqinfo.Name
+			}
+			names[reqinfo.Name] = true
+			out = append(out, reqinfo)
+		}
+	}
+	return out
+}
@@ -0,0 +1,236 @@
+package action
+
+import (
+	"errors"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings shared by the requests of a group, which is a directory of an app.
+// Groups may be nested, e.g. the request users/admin/delete is in the groups
+// users and users/admin.
+type GroupInfo struct {
+	Version     string `yaml:"version"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	// sent with every request of the group, replacing the app's default
+	// headers of the same name
+	Headers []Header `yaml:"headers,omitempty"`
+	// used when neither a stored variable nor --var sets them
+	Variables map[string]string `yaml:"variables,omitempty"`
+}
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// TRUE if name is a valid request or group name, i.e. valid names joined by
+// slashes such as users/create
+func validRequest(name string) bool {
+	for _, s := range strings.Split(name, "/") {
+		if !validName.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func GroupPath(cfgPath, app, group string) string {
+	return path.Join(AppPath(cfgPath, app), group)
+}
+
+func GroupInfoFilePath(cfgPath, app, group string) string {
+	return path.Join(GroupPath(cfgPath, app, group), ".groupinfo")
+}
+
+// Returns an error if saving a request, or a group when group is set, under
+// name would give a request and a group the same name. The request users
+// clashes with the group users, and so with every request in users/.
+func nameConflict(cfgPath, app, name string, group bool) error {
+	segments := strings.Split(name, "/")
+	for i := 1; i < len(segments); i++ {
+		parent := strings.Join(segments[:i], "/")
+		if _, err := os.Stat(ReqPath(cfgPath, app, parent)); err == nil {
+			return errors.New("a request named " + parent + " already exists")
+		}
+	}
+	if group {
+		if _, err := os.Stat(ReqPath(cfgPath, app, name)); err == nil {
+			return errors.New("a request with that name already exists")
+		}
+		return nil
+	}
+	if info, err := os.Stat(GroupPath(cfgPath, app, name)); err == nil && info.IsDir() {
+		return errors.New("a group with that name already exists")
+	}
+	return nil
+}
+
+func WriteGroupInfo(cfgPath, app string, group *GroupInfo) error {
+	if err := nameConflict(cfgPath, app, group.Name, true); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(group)
+	if err != nil {
+		return errors.New("failed to marshal data")
+	}
+	if err := os.MkdirAll(GroupPath(cfgPath, app, group.Name), 0700); err != nil {
+		return err
+	}
+	return writePrivateFile(GroupInfoFilePath(cfgPath, app, group.Name), data)
+}
+
+// Reads the settings of a group. A directory without a .groupinfo file is a
+// group without settings.
+func readGroupInfo(cfgPath, app, group string) (*GroupInfo, error) {
+	if _, err := os.Stat(GroupPath(cfgPath, app, group)); err != nil {
+		return nil, errors.New("group does not exist")
+	}
+	groupinfo := &GroupInfo{Version: "1", Name: group}
+	contents, err := os.ReadFile(GroupInfoFilePath(cfgPath, app, group))
+	if errors.Is(err, os.ErrNotExist) {
+		return groupinfo, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to read group info")
+	}
+	if err := yaml.Unmarshal(contents, groupinfo); err != nil {
+		return nil, errors.New("groupinfo file of " + group + " is malformed or corrupted")
+	}
+	return groupinfo, nil
+}
+
+// Returns the groups a request is in, outermost first
+func requestGroups(cfgPath, app, req string) ([]*GroupInfo, error) {
+	var groups []*GroupInfo
+	segments := strings.Split(req, "/")
+	for i := 1; i < len(segments); i++ {
+		groupinfo, err := readGroupInfo(cfgPath, app, strings.Join(segments[:i], "/"))
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, groupinfo)
+	}
+	return groups, nil
+}
+
+// Returns the stored variables of an app along with those set by groups,
+// which are used when no stored variable has the same name. Inner groups
+// override outer ones.
+func groupVariables(cfgPath, app string, groups []*GroupInfo) (map[string]string, error) {
+	stored, err := ReadVariables(cfgPath, app)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, g := range groups {
+		for k, v := range g.Variables {
+			vars[k] = v
+		}
+	}
+	for k, v := range stored {
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// A request or a group in the tree of an app's requests
+type requestNode struct {
+	// full name, e.g. users/create
+	name string
+	// set for requests
+	reqinfo *RequestInfo
+	// set for groups
+	group    *GroupInfo
+	children []*requestNode
+}
+
+// Reads the requests and groups in the group dir of an app, or at the top of
+// the app if dir is "", sorted by name
+func readRequestTree(cfgPath, app, dir string) ([]*requestNode, error) {
+	files, err := os.ReadDir(GroupPath(cfgPath, app, dir))
+	if err != nil {
+		return nil, errors.New("application does not exist")
+	}
+	var nodes []*requestNode
+	for _, file := range files {
+		name := path.Join(dir, strings.TrimSuffix(file.Name(), ".yml"))
+		if file.IsDir() {
+			if !validRequest(name) {
+				continue
+			}
+			groupinfo, err := readGroupInfo(cfgPath, app, name)
+			if err != nil {
+				return nil, err
+			}
+			children, err := readRequestTree(cfgPath, app, name)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, &requestNode{name: name, group: groupinfo, children: children})
+			continue
+		}
+		if !strings.HasSuffix(file.Name(), ".yml") {
+			continue
+		}
+		contents, err := os.ReadFile(path.Join(GroupPath(cfgPath, app, dir), file.Name()))
+		if err != nil {
+			return nil, errors.New("failed to read request info")
+		}
+		reqinfo := new(RequestInfo)
+		if err := yaml.Unmarshal(contents, reqinfo); err != nil {
+			return nil, errors.New("request file " + path.Join(dir, file.Name()) + " is malformed or corrupted")
+		}
+		nodes = append(nodes, &requestNode{name: name, reqinfo: reqinfo})
+	}
+	return nodes, nil
+}
+
+// Appends the requests in nodes and their groups to reqs, in tree order
+func flattenRequestTree(reqs []*RequestInfo, nodes []*requestNode) []*RequestInfo {
+	for _, node := range nodes {
+		if node.reqinfo != nil {
+			reqs = append(reqs, node.reqinfo)
+		}
+		reqs = flattenRequestTree(reqs, node.children)
+	}
+	return reqs
+}
+
+// A line of a listing, e.g. "create: creates a user" or "users/"
+func (n *requestNode) label() string {
+	name, description := path.Base(n.name), ""
+	if n.reqinfo != nil {
+		description = n.reqinfo.Description
+	} else {
+		name, description = name+"/", n.group.Description
+	}
+	if description == "" {
+		return name
+	}
+	return name + ": " + description
+}
+
+// Renders nodes as a tree, one line each, with the contents of a group drawn
+// as branches below it
+func renderRequestTree(nodes []*requestNode) string {
+	out := ""
+	for _, node := range nodes {
+		out += node.label() + "\n" + renderBranches(node.children, "")
+	}
+	return out
+}
+
+func renderBranches(nodes []*requestNode, indent string) string {
+	out := ""
+	for i, node := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		out += indent + branch + node.label() + "\n" + renderBranches(node.children, indent+next)
+	}
+	return out
+}
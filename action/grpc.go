@@ -0,0 +1,385 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Splits a full method name such as grpc.health.v1.Health/Check into the
+// service and method names. A '.' may be used instead of the '/'.
+func splitRPC(rpc string) (protoreflect.FullName, protoreflect.Name, error) {
+	rpc = strings.TrimPrefix(rpc, "/")
+	i := strings.LastIndex(rpc, "/")
+	if i < 0 {
+		i = strings.LastIndex(rpc, ".")
+	}
+	if i <= 0 || i == len(rpc)-1 {
+		return "", "", errors.New("rpc must be a full method name such as package.Service/Method")
+	}
+	service, method := protoreflect.FullName(rpc[:i]), protoreflect.Name(rpc[i+1:])
+	if !service.IsValid() || !method.IsValid() {
+		return "", "", errors.New("rpc must be a full method name such as package.Service/Method")
+	}
+	return service, method, nil
+}
+
+// Applies --proto and --import-path to an application. Paths are stored as
+// absolute paths so calls work from any directory.
+func grpcFromFlags(ctx *cli.Context, info GRPCInfo) (GRPCInfo, error) {
+	abs := func(flag string) ([]string, error) {
+		var out []string
+		for _, p := range splitList(ctx.StringSlice(flag)) {
+			a, err := filepath.Abs(p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, a)
+		}
+		return out, nil
+	}
+	var err error
+	if ctx.IsSet("proto") {
+		if info.ProtoFiles, err = abs("proto"); err != nil {
+			return info, err
+		}
+	}
+	if ctx.IsSet("import-path") {
+		if info.ImportPaths, err = abs("import-path"); err != nil {
+			return info, err
+		}
+	}
+	return info, nil
+}
+
+// Finds a method in the app's proto files. Files are compiled relative to
+// the first import path that contains them, or to their own directory.
+func methodFromProtoFiles(ctx context.Context, info GRPCInfo, service protoreflect.FullName, method protoreflect.Name) (protoreflect.MethodDescriptor, error) {
+	importPaths := info.ImportPaths
+	var names []string
+	for _, file := range info.ProtoFiles {
+		name := ""
+		for _, p := range importPaths {
+			if rel, err := filepath.Rel(p, file); err == nil && !strings.HasPrefix(rel, "..") {
+				name = rel
+				break
+			}
+		}
+		if name == "" {
+			importPaths = append(importPaths, filepath.Dir(file))
+			name = filepath.Base(file)
+		}
+		names = append(names, name)
+	}
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	files, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, errors.New("failed to compile proto files: " + err.Error())
+	}
+	for _, f := range files {
+		if sd := f.Services().ByName(service.Name()); sd != nil && sd.FullName() == service {
+			return findMethod(sd, method)
+		}
+	}
+	return nil, errors.New("service " + string(service) + " is not defined in the app's proto files")
+}
+
+// Asks the server for the descriptors of a service using server reflection
+func methodFromReflection(ctx context.Context, conn *grpc.ClientConn, service protoreflect.FullName, method protoreflect.Name) (protoreflect.MethodDescriptor, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, errors.New("server reflection is not available: " + err.Error())
+	}
+	defer stream.CloseSend()
+	ask := func(req *rpb.ServerReflectionRequest) ([][]byte, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, errors.New(e.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}
+	raw, err := ask(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: string(service)},
+	})
+	if err != nil {
+		return nil, errors.New("server reflection failed to describe " + string(service) + ": " + status.Convert(err).Message())
+	}
+	// collect the file and every file it depends on
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	for len(raw) > 0 {
+		var missing []string
+		for _, b := range raw {
+			fd := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return nil, errors.New("server reflection returned an invalid descriptor")
+			}
+			files[fd.GetName()] = fd
+		}
+		for _, fd := range files {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := files[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		raw = nil
+		for _, dep := range missing {
+			b, err := ask(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+			if err != nil {
+				return nil, errors.New("server reflection failed to describe " + dep)
+			}
+			raw = append(raw, b...)
+		}
+	}
+	set := new(descriptorpb.FileDescriptorSet)
+	for _, fd := range files {
+		set.File = append(set.File, fd)
+	}
+	registry, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, errors.New("server reflection returned invalid descriptors: " + err.Error())
+	}
+	d, err := registry.FindDescriptorByName(service)
+	if err != nil {
+		return nil, errors.New("service " + string(service) + " does not exist")
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.New(string(service) + " is not a service")
+	}
+	return findMethod(sd, method)
+}
+
+func findMethod(sd protoreflect.ServiceDescriptor, method protoreflect.Name) (protoreflect.MethodDescriptor, error) {
+	md := sd.Methods().ByName(method)
+	if md == nil {
+		return nil, errors.New("service " + string(sd.FullName()) + " does not have a method " + string(method))
+	}
+	if md.IsStreamingClient() {
+		return nil, errors.New("client streaming methods are not supported")
+	}
+	return md, nil
+}
+
+// Dials the app's host. http hosts are dialed in plain text and https hosts
+// over TLS using the app's TLS settings. Connections go through the proxy of
+// every other call with a CONNECT tunnel, so only http and https proxies can
+// be used.
+func dialGRPC(cfgPath string, ctx *cli.Context, appinfo *AppInfo) (*grpc.ClientConn, error) {
+	u, err := url.Parse(appinfo.Host)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("application host is not a valid URL")
+	}
+	var creds credentials.TransportCredentials
+	switch u.Scheme {
+	case "http", "grpc":
+		creds = insecure.NewCredentials()
+	case "https", "grpcs":
+		cfg, err := tlsConfig(appinfo.TLS, ctx.Bool("insecure"))
+		if err != nil {
+			return nil, err
+		}
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		creds = credentials.NewTLS(cfg)
+	default:
+		return nil, errors.New("unsupported grpc scheme " + u.Scheme)
+	}
+	proxy, err := tunnelProxy(cfgPath, ctx, appinfo, u)
+	if err != nil {
+		return nil, err
+	}
+	target := u.Host
+	// grpc would otherwise pick a proxy from the environment on its own
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds), grpc.WithNoProxy()}
+	if proxy != nil {
+		if proxy.Scheme != "http" && proxy.Scheme != "https" {
+			return nil, errTunnelProxy
+		}
+		// the proxy resolves the host, so the address is passed on as it is
+		if u.Port() == "" {
+			target = net.JoinHostPort(u.Hostname(), "443")
+		}
+		target = "passthrough:///" + target
+		opts = append(opts, grpc.WithContextDialer(func(c context.Context, addr string) (net.Conn, error) {
+			var timeout time.Duration
+			if deadline, ok := c.Deadline(); ok {
+				timeout = time.Until(deadline)
+			}
+			return dialTunnel(addr, proxy, ctx.Bool("insecure"), timeout)
+		}))
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, errors.New("failed to connect to " + u.Host)
+	}
+	return conn, nil
+}
+
+// Calls the request's method with its body as the JSON encoded message and
+// prints every response as JSON. Server streaming methods print responses as
+// they arrive until the stream ends or --max-events responses arrived. Calls
+// are saved to the history with the printed responses as the response body.
+func callGRPC(cfgPath string, ctx *cli.Context, stdout io.Writer, appinfo *AppInfo, reqinfo *RequestInfo, vars map[string]string) error {
+	service, method, err := splitRPC(reqinfo.RPC)
+	if err != nil {
+		return err
+	}
+	timeout, err := callTimeout(ctx, reqinfo)
+	if err != nil {
+		return err
+	}
+	body, err := render(reqinfo.Body, vars)
+	if err != nil {
+		return err
+	}
+	c := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(c, timeout)
+		defer cancel()
+	}
+	md := metadata.MD{}
+	var headers []Header
+	for _, h := range reqinfo.Headers {
+		value, err := render(h.Value, vars)
+		if err != nil {
+			return err
+		}
+		md.Append(h.Name, value)
+		headers = append(headers, Header{Name: h.Name, Value: value})
+	}
+	c = metadata.NewOutgoingContext(c, md)
+	conn, err := dialGRPC(cfgPath, ctx, appinfo)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var desc protoreflect.MethodDescriptor
+	if len(appinfo.GRPC.ProtoFiles) > 0 {
+		desc, err = methodFromProtoFiles(c, appinfo.GRPC, service, method)
+	} else {
+		desc, err = methodFromReflection(c, conn, service, method)
+	}
+	if err != nil {
+		return err
+	}
+	in := dynamicpb.NewMessage(desc.Input())
+	if strings.TrimSpace(body) != "" {
+		if err := protojson.Unmarshal([]byte(body), in); err != nil {
+			return errors.New("body is not a valid " + string(desc.Input().FullName()) + ": " + err.Error())
+		}
+	}
+	fullMethod := "/" + string(service) + "/" + string(method)
+	head := &limitedBuffer{limit: maxHistoryBody}
+	t1 := time.Now()
+	err = invokeGRPC(c, ctx, io.MultiWriter(stdout, head), conn, desc, fullMethod, in)
+	entry := &HistoryEntry{
+		Timestamp:      time.Now(),
+		App:            appinfo.Name,
+		Request:        reqinfo.Name,
+		Vars:           vars,
+		Method:         "GRPC",
+		URL:            strings.TrimSuffix(appinfo.Host, "/") + fullMethod,
+		RequestHeaders: redactHeaders(headers),
+		RequestBody:    body,
+		Status:         status.Code(err).String(),
+		ResponseBody:   head.String(),
+		Truncated:      head.total > int64(head.Len()),
+		Latency:        time.Since(t1).String(),
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			err = grpcError(err)
+		}
+		entry.Error = err.Error()
+	}
+	recordHistory(cfgPath, entry)
+	return err
+}
+
+// Sends in to the method and prints every response to stdout
+func invokeGRPC(c context.Context, ctx *cli.Context, stdout io.Writer, conn *grpc.ClientConn, desc protoreflect.MethodDescriptor, fullMethod string, in proto.Message) error {
+	printMessage := func(m proto.Message) error {
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return errors.New("failed to encode response")
+		}
+		// protojson varies its whitespace on purpose, so it is indented here
+		var out bytes.Buffer
+		if err := json.Indent(&out, b, "", "  "); err != nil {
+			return errors.New("failed to encode response")
+		}
+		fmt.Fprintln(stdout, out.String())
+		return nil
+	}
+	if !desc.IsStreamingServer() {
+		out := dynamicpb.NewMessage(desc.Output())
+		if err := conn.Invoke(c, fullMethod, in, out); err != nil {
+			return err
+		}
+		return printMessage(out)
+	}
+	stream, err := conn.NewStream(c, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(in); err != nil {
+		return err
+	}
+	stream.CloseSend()
+	for received := 0; ctx.Int("max-events") <= 0 || received < ctx.Int("max-events"); received++ {
+		out := dynamicpb.NewMessage(desc.Output())
+		err := stream.RecvMsg(out)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := printMessage(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func grpcError(err error) error {
+	s := status.Convert(err)
+	return errors.New("grpc call failed: " + s.Code().String() + ": " + s.Message())
+}
@@ -0,0 +1,166 @@
+package action
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Header struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+func (h Header) String() string {
+	return h.Name + ": " + h.Value
+}
+
+// Request files written before headers were structured store them as
+// "Name: value" strings, so both forms are accepted. Names are validated but
+// otherwise kept as they were saved.
+func (h *Header) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		parsed, err := splitHeader(node.Value)
+		if err != nil {
+			return err
+		}
+		*h = parsed
+		return nil
+	}
+	type plain Header
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	if !validHeaderName(p.Name) {
+		return errors.New("invalid header name " + p.Name)
+	}
+	*h = Header(p)
+	return nil
+}
+
+// TRUE if name is a valid HTTP token, as header names and methods must be
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func splitHeader(s string) (Header, error) {
+	name, value, found := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !found || !validHeaderName(name) {
+		return Header{}, errors.New("malformed header " + s + ", expected \"Name: value\"")
+	}
+	return Header{Name: name, Value: strings.TrimSpace(value)}, nil
+}
+
+// Parses a header in the form "Name: value". The name is canonicalized and
+// surrounding whitespace is removed from both parts.
+func ParseHeader(s string) (Header, error) {
+	h, err := splitHeader(s)
+	if err != nil {
+		return h, err
+	}
+	h.Name = http.CanonicalHeaderKey(h.Name)
+	return h, nil
+}
+
+func parseHeaders(raw []string) ([]Header, error) {
+	var headers []Header
+	for _, s := range raw {
+		h, err := ParseHeader(s)
+		if err != nil {
+			return nil, err
+		}
+		headers = addHeader(headers, h)
+	}
+	return headers, nil
+}
+
+// Appends h unless the exact same header is already present. Repeated names
+// with different values are kept, since HTTP allows them.
+func addHeader(headers []Header, h Header) []Header {
+	for _, existing := range headers {
+		if existing == h {
+			return headers
+		}
+	}
+	return append(headers, h)
+}
+
+// Replaces every header named h.Name with h, keeping the position of the first
+// one. h is appended if no header has that name. Names are compared without
+// regard to case since saved names are not canonicalized.
+func setHeader(headers []Header, h Header) []Header {
+	out := make([]Header, 0, len(headers)+1)
+	set := false
+	for _, existing := range headers {
+		if !strings.EqualFold(existing.Name, h.Name) {
+			out = append(out, existing)
+		} else if !set {
+			out = append(out, h)
+			set = true
+		}
+	}
+	if !set {
+		out = append(out, h)
+	}
+	return out
+}
+
+// Removes every header with the given name, in any case
+func removeHeader(headers []Header, name string) []Header {
+	name = strings.TrimSpace(name)
+	out := make([]Header, 0, len(headers))
+	for _, existing := range headers {
+		if !strings.EqualFold(existing.Name, name) {
+			out = append(out, existing)
+		}
+	}
+	return out
+}
+
+// Applies --header, --remove-header, --set-header and --add-header, in that order
+func headersFromFlags(ctx *cli.Context, headers []Header) ([]Header, error) {
+	if ctx.IsSet("header") {
+		replaced, err := parseHeaders(ctx.StringSlice("header"))
+		if err != nil {
+			return nil, err
+		}
+		headers = replaced
+	}
+	for _, name := range ctx.StringSlice("remove-header") {
+		if !validHeaderName(strings.TrimSpace(name)) {
+			return nil, errors.New("invalid header name " + name)
+		}
+		headers = removeHeader(headers, name)
+	}
+	for _, s := range ctx.StringSlice("set-header") {
+		h, err := ParseHeader(s)
+		if err != nil {
+			return nil, err
+		}
+		headers = setHeader(headers, h)
+	}
+	for _, s := range ctx.StringSlice("add-header") {
+		h, err := ParseHeader(s)
+		if err != nil {
+			return nil, err
+		}
+		headers = addHeader(headers, h)
+	}
+	return headers, nil
+}
@@ -2,13 +2,20 @@ package action
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
@@ -51,6 +58,160 @@ func appExists(cfgPath, app string) bool {
 	return err == nil
 }
 
+// Applies any TLS flags that were set on the command to info.
+// Certificate and key paths are made absolute.
+func tlsFromFlags(ctx *cli.Context, info TLSInfo) (TLSInfo, error) {
+	files := map[string]*string{
+		"ca-cert": &info.CACert,
+		"cert":    &info.ClientCert,
+		"key":     &info.ClientKey,
+	}
+	for flag, field := range files {
+		if !ctx.IsSet(flag) {
+			continue
+		}
+		if ctx.String(flag) == "" {
+			*field = ""
+			continue
+		}
+		abs, err := filepath.Abs(ctx.String(flag))
+		if err != nil {
+			return info, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return info, errors.New("file " + ctx.String(flag) + " does not exist")
+		}
+		*field = abs
+	}
+	if ctx.IsSet("server-name") {
+		info.ServerName = ctx.String("server-name")
+	}
+	if ctx.IsSet("tls-min-version") {
+		if !validTLSVersion(ctx.String("tls-min-version")) {
+			return info, errors.New("minimum TLS version must be one of 1.0, 1.1, 1.2 or 1.3")
+		}
+		info.MinVersion = ctx.String("tls-min-version")
+	}
+	if ctx.IsSet("insecure") {
+		info.Insecure = ctx.Bool("insecure")
+	}
+	return info, nil
+}
+
+// Applies any proxy flags that were set on the command to info.
+func proxyFromFlags(ctx *cli.Context, info ProxyInfo) (ProxyInfo, error) {
+	if ctx.IsSet("proxy") {
+		info.URL = ctx.String("proxy")
+	}
+	if ctx.IsSet("proxy-user") {
+		if info.URL == "" {
+			return info, errors.New("proxy credentials require a proxy")
+		}
+		u, err := url.Parse(info.URL)
+		if err != nil {
+			return info, errors.New("proxy URL is invalid")
+		}
+		user, pass, found := strings.Cut(ctx.String("proxy-user"), ":")
+		if !found {
+			u.User = url.User(user)
+		} else {
+			u.User = url.UserPassword(user, pass)
+		}
+		info.URL = u.String()
+	}
+	if ctx.IsSet("no-proxy") {
+		info.NoProxy = splitList(ctx.StringSlice("no-proxy"))
+	}
+	if _, err := parseProxyURL(info.URL); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// Applies any timeout and retry flags that were set on the command to reqinfo
+func retryFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	if ctx.IsSet("timeout") {
+		if err := validDuration("timeout", ctx.String("timeout")); err != nil {
+			return err
+		}
+		reqinfo.Timeout = ctx.String("timeout")
+	}
+	if ctx.IsSet("retries") {
+		if ctx.Int("retries") < 0 {
+			return errors.New("retries must not be negative")
+		}
+		reqinfo.Retries = ctx.Int("retries")
+	}
+	if ctx.IsSet("retry-on") {
+		on := splitList(ctx.StringSlice("retry-on"))
+		if err := validRetryOn(on); err != nil {
+			return err
+		}
+		reqinfo.RetryOn = on
+	}
+	if ctx.IsSet("retry-delay") {
+		if err := validDuration("retry delay", ctx.String("retry-delay")); err != nil {
+			return err
+		}
+		reqinfo.RetryDelay = ctx.String("retry-delay")
+	}
+	return nil
+}
+
+// kinds of request sp9rk can make, "" is the same as http
+var requestKinds = map[string]bool{"": true, "http": true, "websocket": true, "graphql": true, "grpc": true}
+
+// Applies --kind, --message and --rpc to a request
+func kindFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	if ctx.IsSet("kind") {
+		if !requestKinds[ctx.String("kind")] {
+			return errors.New("unsupported request kind " + ctx.String("kind"))
+		}
+		reqinfo.Kind = ctx.String("kind")
+	}
+	if ctx.IsSet("message") {
+		reqinfo.Messages = ctx.StringSlice("message")
+	}
+	if ctx.IsSet("rpc") {
+		if _, _, err := splitRPC(ctx.String("rpc")); err != nil {
+			return err
+		}
+		reqinfo.RPC = ctx.String("rpc")
+	}
+	return nil
+}
+
+// Splits comma separated flag values, e.g. --retry-on 502,503 --retry-on 504
+func splitList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// Serves handler on listener until the command's context is cancelled or the
+// user interrupts it, then waits a few seconds for open requests to finish
+func serveUntilInterrupted(ctx *cli.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{Handler: handler}
+	c, stop := signal.NotifyContext(ctx.Context, os.Interrupt)
+	defer stop()
+	go func() {
+		<-c.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.New("server failed: " + err.Error())
+	}
+	return nil
+}
+
 func ConfirmPrompt() bool {
 	fmt.Print("Are you sure? [y/N]: ")
 	r := bufio.NewReader(os.Stdin)
@@ -73,6 +234,10 @@ func AppInfoFilePath(cfgPath, app string) string {
 	return path.Join(AppPath(cfgPath, app), ".appinfo")
 }
 
+func ConfigFilePath(cfgPath string) string {
+	return path.Join(cfgPath, "config.yml")
+}
+
 func ReqPath(cfgPath, app, req string) string {
 	return path.Join(cfgPath, "apps", app, req+".yml")
 }
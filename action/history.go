@@ -0,0 +1,259 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultHistoryLimit = 100
+	// bodies larger than this are truncated before being saved
+	maxHistoryBody = 64 << 10
+)
+
+// A call as it was sent and received
+type HistoryEntry struct {
+	ID              int               `yaml:"id"`
+	Timestamp       time.Time         `yaml:"timestamp"`
+	App             string            `yaml:"app"`
+	Request         string            `yaml:"request"`
+	Vars            map[string]string `yaml:"vars,omitempty"`
+	Method          string            `yaml:"method"`
+	URL             string            `yaml:"url"`
+	RequestHeaders  []Header          `yaml:"request_headers,omitempty"`
+	RequestBody     string            `yaml:"request_body,omitempty"`
+	Status          string            `yaml:"status,omitempty"`
+	StatusCode      int               `yaml:"status_code,omitempty"`
+	ResponseHeaders []Header          `yaml:"response_headers,omitempty"`
+	ResponseBody    string            `yaml:"response_body,omitempty"`
+	Truncated       bool              `yaml:"truncated,omitempty"`
+	Latency         string            `yaml:"latency"`
+	Error           string            `yaml:"error,omitempty"`
+}
+
+func HistoryPath(cfgPath string) string {
+	return path.Join(cfgPath, "history")
+}
+
+func historyFilePath(cfgPath string, id int) string {
+	return path.Join(HistoryPath(cfgPath), fmt.Sprintf("%08d.yml", id))
+}
+
+// stands in for the value of a header that carries credentials
+const redactedValue = "[redacted]"
+
+// headers whose values are not written to the history
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"Set-Cookie":          true,
+}
+
+// Replaces the values of headers that carry credentials
+func redactHeaders(headers []Header) []Header {
+	for i, h := range headers {
+		if redactedHeaders[http.CanonicalHeaderKey(h.Name)] {
+			headers[i].Value = redactedValue
+		}
+	}
+	return headers
+}
+
+// Replaces the values of the entry's variables and query parameters, which
+// may hold captured tokens or other credentials
+func redactValues(entry *HistoryEntry) {
+	if len(entry.Vars) > 0 {
+		vars := make(map[string]string, len(entry.Vars))
+		for k := range entry.Vars {
+			vars[k] = redactedValue
+		}
+		entry.Vars = vars
+	}
+	u, err := url.Parse(entry.URL)
+	if err != nil || u.RawQuery == "" {
+		return
+	}
+	query := u.Query()
+	for _, values := range query {
+		for i := range values {
+			values[i] = redactedValue
+		}
+	}
+	u.RawQuery = query.Encode()
+	entry.URL = u.String()
+}
+
+// Flattens headers into a sorted list so entries are stable
+func headerList(h http.Header) []Header {
+	var headers []Header
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, Header{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// Records the outcome of sending req. resp and respBody are ignored if err is
+// set. Credentials in headers are redacted.
+func newHistoryEntry(app, reqName string, vars map[string]string, req *http.Request, resp *http.Response, respBody []byte, latency time.Duration, err error) *HistoryEntry {
+	entry := &HistoryEntry{
+		Timestamp:      time.Now(),
+		App:            app,
+		Request:        reqName,
+		Vars:           vars,
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: redactHeaders(headerList(req.Header)),
+		Latency:        latency.String(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			entry.RequestBody = string(b)
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Status = resp.Status
+	entry.StatusCode = resp.StatusCode
+	entry.ResponseHeaders = redactHeaders(headerList(resp.Header))
+	if len(respBody) > maxHistoryBody {
+		respBody = respBody[:maxHistoryBody]
+		entry.Truncated = true
+	}
+	entry.ResponseBody = string(respBody)
+	return entry
+}
+
+// Returns the ids of every saved entry, oldest first
+func historyIDs(cfgPath string) ([]int, error) {
+	files, err := os.ReadDir(HistoryPath(cfgPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to read history")
+	}
+	var ids []int
+	for _, f := range files {
+		id, err := strconv.Atoi(strings.TrimSuffix(f.Name(), ".yml"))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Saves the entry under the next free id and removes the oldest entries once
+// there are more than the configured limit. A negative limit disables history.
+// Values of variables and query parameters are redacted unless the config
+// says to keep them.
+func WriteHistory(cfgPath string, entry *HistoryEntry) error {
+	cfg, err := ReadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+	limit := cfg.HistoryLimit
+	if limit < 0 {
+		return nil
+	}
+	if !cfg.HistoryValues {
+		redactValues(entry)
+	}
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+	if err := os.MkdirAll(HistoryPath(cfgPath), 0700); err != nil {
+		return err
+	}
+	ids, err := historyIDs(cfgPath)
+	if err != nil {
+		return err
+	}
+	entry.ID = 1
+	if len(ids) > 0 {
+		entry.ID = ids[len(ids)-1] + 1
+	}
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return errors.New("failed to marshal data")
+	}
+	// another call may have taken the id in the meantime
+	for {
+		f, err := os.OpenFile(historyFilePath(cfgPath, entry.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			entry.ID++
+			data, _ = yaml.Marshal(entry)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		break
+	}
+	ids = append(ids, entry.ID)
+	for len(ids) > limit {
+		os.Remove(historyFilePath(cfgPath, ids[0]))
+		ids = ids[1:]
+	}
+	return nil
+}
+
+func ReadHistoryEntry(cfgPath string, id int) (*HistoryEntry, error) {
+	contents, err := os.ReadFile(historyFilePath(cfgPath, id))
+	if err != nil {
+		return nil, fmt.Errorf("history entry %d does not exist", id)
+	}
+	entry := new(HistoryEntry)
+	if err := yaml.Unmarshal(contents, entry); err != nil {
+		return nil, fmt.Errorf("history entry %d is malformed or corrupted", id)
+	}
+	return entry, nil
+}
+
+// Returns every saved entry, oldest first
+func ReadHistory(cfgPath string) ([]*HistoryEntry, error) {
+	ids, err := historyIDs(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*HistoryEntry, 0, len(ids))
+	for _, id := range ids {
+		entry, err := ReadHistoryEntry(cfgPath, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Saves the entry, warning instead of failing the call if it cannot be saved
+func recordHistory(cfgPath string, entry *HistoryEntry) {
+	if err := WriteHistory(cfgPath, entry); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: failed to save call to history: "+err.Error())
+	}
+}
@@ -0,0 +1,206 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Commands run around every call of an http or graphql request. They are run
+// through the shell, receive the request or response as JSON on stdin and may
+// answer with changes as JSON on stdout. App hooks run before the request's
+// pre_request hook and after its post_response hook.
+type Hooks struct {
+	PreRequest   string `yaml:"pre_request,omitempty"`
+	PostResponse string `yaml:"post_response,omitempty"`
+}
+
+// What a pre_request hook receives
+type hookRequest struct {
+	App     string              `json:"app"`
+	Request string              `json:"request"`
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+	Vars    map[string]string   `json:"vars"`
+}
+
+// What a post_response hook receives
+type hookResponse struct {
+	App     string              `json:"app"`
+	Request string              `json:"request"`
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+	Latency string              `json:"latency"`
+	Vars    map[string]string   `json:"vars"`
+}
+
+// What a hook may answer with. Fields that are left out are not changed, and
+// a header set to an empty list is removed. Error fails the call.
+type hookResult struct {
+	Method  *string             `json:"method"`
+	URL     *string             `json:"url"`
+	Headers map[string][]string `json:"headers"`
+	Body    *string             `json:"body"`
+	// stored like captured values
+	Vars  map[string]string `json:"vars"`
+	Error string            `json:"error"`
+}
+
+// Applies --pre-request and --post-response. An empty command removes a hook.
+func hooksFromFlags(ctx *cli.Context, hooks Hooks) Hooks {
+	if ctx.IsSet("pre-request") {
+		hooks.PreRequest = ctx.String("pre-request")
+	}
+	if ctx.IsSet("post-response") {
+		hooks.PostResponse = ctx.String("post-response")
+	}
+	return hooks
+}
+
+// Checks the hooks of a request saved without flags, e.g. after editing it.
+// Hooks only run around http and graphql calls.
+func validHooks(reqinfo *RequestInfo) error {
+	for _, h := range []struct{ name, command string }{
+		{"pre_request", reqinfo.Hooks.PreRequest},
+		{"post_response", reqinfo.Hooks.PostResponse},
+	} {
+		if h.command != "" && strings.TrimSpace(h.command) == "" {
+			return errors.New(h.name + " hook must not be blank")
+		}
+	}
+	if reqinfo.Hooks != (Hooks{}) && (reqinfo.Kind == "websocket" || reqinfo.Kind == "grpc") {
+		return errors.New("hooks only apply to http and graphql requests")
+	}
+	return nil
+}
+
+// Runs a hook command with input encoded as JSON on its stdin. Anything the
+// command writes to stderr is passed on to stderr.
+func runHook(name, command string, input any, stderr io.Writer) (*hookResult, error) {
+	in, err := json.Marshal(input)
+	if err != nil {
+		return nil, errors.New("failed to encode " + name + " hook input")
+	}
+	var out bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "SP9RK_HOOK="+name)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return nil, fmt.Errorf("%s hook exited with status %d", name, exit.ExitCode())
+		}
+		return nil, errors.New("failed to run " + name + " hook: " + err.Error())
+	}
+	result := new(hookResult)
+	if strings.TrimSpace(out.String()) == "" {
+		return result, nil
+	}
+	if err := json.Unmarshal(out.Bytes(), result); err != nil {
+		return nil, errors.New(name + " hook did not answer with a JSON object")
+	}
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return result, nil
+}
+
+// Runs the app's and the request's pre_request hooks, applying their changes
+// to req. Variables they set are added to vars and returned so they can be
+// stored.
+func preRequestHooks(appinfo *AppInfo, reqinfo *RequestInfo, req *http.Request, vars map[string]string, stderr io.Writer) (map[string]string, error) {
+	set := make(map[string]string)
+	for _, command := range []string{appinfo.Hooks.PreRequest, reqinfo.Hooks.PreRequest} {
+		if command == "" {
+			continue
+		}
+		input := hookRequest{
+			App:     appinfo.Name,
+			Request: reqinfo.Name,
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: req.Header,
+			Vars:    vars,
+		}
+		if req.GetBody != nil {
+			body, _ := req.GetBody()
+			b, _ := io.ReadAll(body)
+			input.Body = string(b)
+		}
+		result, err := runHook("pre_request", command, input, stderr)
+		if err != nil {
+			return nil, err
+		}
+		if result.Method != nil {
+			if !validHeaderName(*result.Method) {
+				return nil, errors.New("pre_request hook answered with an invalid method")
+			}
+			req.Method = *result.Method
+		}
+		if result.URL != nil {
+			u, err := url.Parse(*result.URL)
+			if err != nil || u.Host == "" {
+				return nil, errors.New("pre_request hook answered with an invalid url")
+			}
+			req.URL, req.Host = u, u.Host
+		}
+		for name, values := range result.Headers {
+			req.Header.Del(name)
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		if result.Body != nil {
+			body := []byte(*result.Body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+			req.ContentLength = int64(len(body))
+		}
+		for k, v := range result.Vars {
+			vars[k], set[k] = v, v
+		}
+	}
+	return set, nil
+}
+
+// Runs the request's and the app's post_response hooks and returns the
+// variables they set
+func postResponseHooks(appinfo *AppInfo, reqinfo *RequestInfo, resp *http.Response, body []byte, latency time.Duration, vars map[string]string, stderr io.Writer) (map[string]string, error) {
+	set := make(map[string]string)
+	for _, command := range []string{reqinfo.Hooks.PostResponse, appinfo.Hooks.PostResponse} {
+		if command == "" {
+			continue
+		}
+		result, err := runHook("post_response", command, hookResponse{
+			App:     appinfo.Name,
+			Request: reqinfo.Name,
+			Status:  resp.StatusCode,
+			Headers: resp.Header,
+			Body:    string(body),
+			Latency: latency.String(),
+			Vars:    vars,
+		}, stderr)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range result.Vars {
+			vars[k], set[k] = v, v
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,190 @@
+package action
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// headers that describe how a saved response was transferred rather than the
+// response itself
+var mockSkipHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Date":              true,
+}
+
+// A saved request the mock server answers. Path segments containing template
+// actions such as {{.id}} match any value.
+type mockRoute struct {
+	method   string
+	segments []string
+	wildcard []bool
+	reqinfo  *RequestInfo
+}
+
+func splitSegments(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func newMockRoute(basePath string, reqinfo *RequestInfo) mockRoute {
+	reqPath, _, _ := strings.Cut(reqinfo.Path, "?")
+	r := mockRoute{
+		method:   strings.ToUpper(reqinfo.Method),
+		segments: splitSegments(path.Join("/", basePath, reqPath)),
+		reqinfo:  reqinfo,
+	}
+	for _, s := range r.segments {
+		r.wildcard = append(r.wildcard, strings.Contains(s, "{{"))
+	}
+	return r
+}
+
+func (r mockRoute) match(method string, segments []string) bool {
+	if r.method != method || len(r.segments) != len(segments) {
+		return false
+	}
+	for i, s := range segments {
+		if !r.wildcard[i] && r.segments[i] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// number of literal segments, routes with more of them are preferred
+func (r mockRoute) literals() int {
+	n := 0
+	for _, w := range r.wildcard {
+		if !w {
+			n++
+		}
+	}
+	return n
+}
+
+// Serves the example response of the saved request matching each call, or the
+// request's snapshot if it does not have an example
+type mockServer struct {
+	cfgPath string
+	app     string
+	routes  []mockRoute
+	// added before responses without a latency of their own
+	latency time.Duration
+}
+
+func newMockServer(cfgPath, app string, appinfo *AppInfo, reqs []*RequestInfo, latency time.Duration) (*mockServer, error) {
+	var basePath string
+	if u, err := url.Parse(appinfo.Host); err == nil {
+		basePath = u.Path
+	}
+	s := &mockServer{cfgPath: cfgPath, app: app, latency: latency}
+	for _, reqinfo := range reqs {
+		if reqinfo.Kind != "" && reqinfo.Kind != "http" && reqinfo.Kind != "graphql" {
+			continue
+		}
+		if reqinfo.Kind == "graphql" {
+			reqinfo.Method = "POST"
+		}
+		if reqinfo.Example != nil {
+			if err := validDuration("latency of "+reqinfo.Name, reqinfo.Example.Latency); err != nil {
+				return nil, err
+			}
+		}
+		s.routes = append(s.routes, newMockRoute(basePath, reqinfo))
+	}
+	sort.SliceStable(s.routes, func(i, j int) bool { return s.routes[i].literals() > s.routes[j].literals() })
+	return s, nil
+}
+
+func (s *mockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitSegments(r.URL.Path)
+	var route *mockRoute
+	for i := range s.routes {
+		if s.routes[i].match(r.Method, segments) {
+			route = &s.routes[i]
+			break
+		}
+	}
+	if route == nil {
+		fmt.Printf("%s %s -> no matching request\n", r.Method, r.URL.Path)
+		http.Error(w, "no saved request matches "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	example := route.reqinfo.Example
+	if example == nil {
+		if snapshot, err := ReadSnapshot(s.cfgPath, s.app, route.reqinfo.Name); err == nil {
+			example = &Example{Status: snapshot.StatusCode, Headers: snapshot.Headers, Body: snapshot.Body}
+		}
+	}
+	if example == nil {
+		fmt.Printf("%s %s -> %s has no example\n", r.Method, r.URL.Path, route.reqinfo.Name)
+		http.Error(w, "request "+route.reqinfo.Name+" does not have an example or snapshot", http.StatusNotImplemented)
+		return
+	}
+	latency := s.latency
+	if example.Latency != "" {
+		latency, _ = time.ParseDuration(example.Latency)
+	}
+	select {
+	case <-time.After(latency):
+	case <-r.Context().Done():
+		return
+	}
+	for _, h := range example.Headers {
+		if !mockSkipHeaders[http.CanonicalHeaderKey(h.Name)] {
+			w.Header().Add(h.Name, h.Value)
+		}
+	}
+	status := example.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(example.Body))
+	fmt.Printf("%s %s -> %s %d\n", r.Method, r.URL.Path, route.reqinfo.Name, status)
+}
+
+// Applies the --example-* flags to a request
+func exampleFromFlags(ctx *cli.Context, reqinfo *RequestInfo) error {
+	if !ctx.IsSet("example-status") && !ctx.IsSet("example-body") && !ctx.IsSet("example-header") && !ctx.IsSet("example-latency") {
+		return nil
+	}
+	if reqinfo.Example == nil {
+		reqinfo.Example = &Example{Status: http.StatusOK}
+	}
+	if ctx.IsSet("example-status") {
+		if ctx.Int("example-status") < 100 || ctx.Int("example-status") > 599 {
+			return fmt.Errorf("%d is not a valid status code", ctx.Int("example-status"))
+		}
+		reqinfo.Example.Status = ctx.Int("example-status")
+	}
+	if ctx.IsSet("example-body") {
+		reqinfo.Example.Body = ctx.String("example-body")
+	}
+	if ctx.IsSet("example-header") {
+		headers, err := parseHeaders(ctx.StringSlice("example-header"))
+		if err != nil {
+			return err
+		}
+		reqinfo.Example.Headers = headers
+	}
+	if ctx.IsSet("example-latency") {
+		if err := validDuration("example-latency", ctx.String("example-latency")); err != nil {
+			return err
+		}
+		reqinfo.Example.Latency = ctx.String("example-latency")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// request and response bodies larger than this are not recorded
+	maxRecordedBody = 1 << 20
+)
+
+// headers set by the client's transport or meant for a proxy, which are not
+// worth saving
+var recordSkipHeaders = map[string]bool{
+	"Accept-Encoding":     true,
+	"Connection":          true,
+	"Content-Length":      true,
+	"Host":                true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"User-Agent":          true,
+}
+
+// credentials a client sent, which are saved as variables to set before
+// calling the recorded request rather than in plain text
+var recordCredentialHeaders = map[string]string{
+	"Authorization": "{{.authorization}}",
+	"Cookie":        "{{.cookie}}",
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Names a recorded request after its method and path, e.g. GET /users/42
+// becomes get-users-42
+func recordedName(method, p string) string {
+	name := strings.ToLower(method)
+	for _, s := range splitSegments(p) {
+		if s = strings.Trim(invalidNameChars.ReplaceAllString(s, "-"), "-"); s != "" {
+			name += "-" + s
+		}
+	}
+	return name
+}
+
+// Captures the status and body of a response while passing it on to the client
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   limitedBuffer
+	// set when the target could not be reached
+	failed bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Forwards every request to the target and saves each distinct method and
+// path as a request of the app, with the first response as its example
+type recorder struct {
+	cfgPath string
+	app     string
+	proxy   *httputil.ReverseProxy
+	mu      sync.Mutex
+	// names of the requests saved so far by method and path
+	seen map[string]string
+}
+
+func newRecorder(cfgPath, app string, target *url.URL, reqs []*RequestInfo) *recorder {
+	r := &recorder{cfgPath: cfgPath, app: app, seen: make(map[string]string)}
+	for _, reqinfo := range reqs {
+		reqPath, _, _ := strings.Cut(reqinfo.Path, "?")
+		r.seen[strings.ToUpper(reqinfo.Method)+" "+cleanPath(reqPath)] = reqinfo.Name
+	}
+	r.proxy = httputil.NewSingleHostReverseProxy(target)
+	director := r.proxy.Director
+	r.proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = target.Host
+	}
+	r.proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		fmt.Fprintf(os.Stderr, "%s %s -> failed to reach %s: %s\n", req.Method, req.URL.Path, target.Host, err)
+		if rw, ok := w.(*recordingWriter); ok {
+			rw.failed = true
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return r
+}
+
+// Cleans a request path so /users and /users/ are the same request
+func cleanPath(p string) string {
+	if p = strings.TrimRight(p, "/"); p == "" {
+		return "/"
+	}
+	return p
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body limitedBuffer
+	body.limit = maxRecordedBody
+	if req.Body != nil {
+		contents, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		body.Write(contents)
+		req.Body = io.NopCloser(bytes.NewReader(contents))
+	}
+	headers := req.Header.Clone()
+	// ask for an uncompressed response so the example is readable
+	req.Header.Del("Accept-Encoding")
+	rw := &recordingWriter{ResponseWriter: w, body: limitedBuffer{limit: maxRecordedBody}}
+	r.proxy.ServeHTTP(rw, req)
+	if rw.failed {
+		return
+	}
+	name, err := r.record(req, headers, &body, rw)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s %s -> %d, failed to record: %s\n", req.Method, req.URL.Path, rw.status, err)
+	case name != "":
+		fmt.Printf("%s %s -> %d, recorded %s\n", req.Method, req.URL.Path, rw.status, name)
+	default:
+		fmt.Printf("%s %s -> %d\n", req.Method, req.URL.Path, rw.status)
+	}
+}
+
+// Saves the request if its method and path have not been seen before and
+// returns its name, or "" if it was already saved
+func (r *recorder) record(req *http.Request, headers http.Header, body *limitedBuffer, rw *recordingWriter) (string, error) {
+	key := req.Method + " " + cleanPath(req.URL.Path)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[key]; ok {
+		return "", nil
+	}
+	if body.total > int64(body.limit) || rw.body.total > int64(rw.body.limit) {
+		return "", fmt.Errorf("bodies larger than %s are not recorded", formatBytes(maxRecordedBody))
+	}
+	name := recordedName(req.Method, req.URL.Path)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(ReqPath(r.cfgPath, r.app, name)); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", recordedName(req.Method, req.URL.Path), i)
+	}
+	reqinfo := &RequestInfo{
+		Version:     "1",
+		Name:        name,
+		Description: "recorded from " + key,
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Body:        body.String(),
+		Example: &Example{
+			Status: rw.status,
+			Body:   rw.body.String(),
+		},
+	}
+	if query := req.URL.Query(); len(query) > 0 {
+		reqinfo.Query = Query(query)
+	}
+	respHeaders := rw.Header().Clone()
+	for name := range recordSkipHeaders {
+		headers.Del(name)
+	}
+	for name, placeholder := range recordCredentialHeaders {
+		if _, ok := headers[name]; ok {
+			headers.Set(name, placeholder)
+		}
+	}
+	for name := range mockSkipHeaders {
+		respHeaders.Del(name)
+	}
+	reqinfo.Headers = headerList(headers)
+	reqinfo.Example.Headers = headerList(respHeaders)
+	if err := WriteRequestFiles(r.cfgPath, r.app, reqinfo); err != nil {
+		return "", err
+	}
+	r.seen[key] = name
+	return name, nil
+}
@@ -0,0 +1,101 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Joins the app's host with the request's path and query parameters. Query
+// parameters written directly into the path are kept and merged with query,
+// with query taking precedence.
+func buildURL(host, reqPath string, query Query) (*url.URL, error) {
+	base, err := url.Parse(host)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, errors.New("application host " + host + " is not a valid URL")
+	}
+	ref, err := url.Parse(reqPath)
+	if err != nil {
+		return nil, errors.New("request path " + reqPath + " is invalid")
+	}
+	// the paths are joined escaped so that %2F and %25 keep their meaning
+	escaped := path.Join("/", base.EscapedPath(), ref.EscapedPath())
+	if strings.HasSuffix(ref.EscapedPath(), "/") && !strings.HasSuffix(escaped, "/") {
+		escaped += "/"
+	}
+	if base.EscapedPath() == "" && ref.EscapedPath() == "" {
+		escaped = ""
+	}
+	unescaped, err := url.PathUnescape(escaped)
+	if err != nil {
+		return nil, errors.New("request path " + reqPath + " is invalid")
+	}
+	u := *base
+	u.Path, u.RawPath = unescaped, escaped
+	q := base.Query()
+	for k, v := range ref.Query() {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return &u, nil
+}
+
+// Sets each key in updates to its values, replacing any existing values
+func mergeQuery(query, updates Query) Query {
+	if len(updates) == 0 {
+		return query
+	}
+	out := make(Query, len(query)+len(updates))
+	for k, v := range query {
+		out[k] = v
+	}
+	for k, v := range updates {
+		out[k] = v
+	}
+	return out
+}
+
+// Builds the HTTP request described by reqinfo, rendering templates in the
+// path, query values, headers and body with vars.
+func newRequest(appinfo *AppInfo, reqinfo *RequestInfo, vars map[string]string) (*http.Request, error) {
+	reqPath, err := render(reqinfo.Path, vars)
+	if err != nil {
+		return nil, err
+	}
+	query := make(Query, len(reqinfo.Query))
+	for k, values := range reqinfo.Query {
+		for _, v := range values {
+			rendered, err := render(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			query[k] = append(query[k], rendered)
+		}
+	}
+	u, err := buildURL(appinfo.Host, reqPath, query)
+	if err != nil {
+		return nil, err
+	}
+	body, err := render(reqinfo.Body, vars)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(reqinfo.Method, u.String(), bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		return nil, errors.New("failed to create web request")
+	}
+	for _, header := range reqinfo.Headers {
+		value, err := render(header.Value, vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add(header.Name, value)
+	}
+	return req, nil
+}
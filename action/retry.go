@@ -0,0 +1,180 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	defaultRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+	connErrorRetry    = "conn-error"
+)
+
+var defaultRetryOn = []string{"502", "503", "504", connErrorRetry}
+
+// Methods that are safe to send again without an explicit --retry-on
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// TRUE if the request may be retried by default. Like net/http, a request
+// with an Idempotency-Key header is treated as idempotent.
+func idempotent(req *http.Request) bool {
+	return idempotentMethods[req.Method] || req.Header.Get("Idempotency-Key") != ""
+}
+
+// Describes when and how often a call is retried
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+	codes   map[int]bool
+	conn    bool
+}
+
+// TRUE if every condition is a status code or conn-error
+func validRetryOn(conditions []string) error {
+	for _, c := range conditions {
+		if c == connErrorRetry {
+			continue
+		}
+		code, err := strconv.Atoi(c)
+		if err != nil || code < 100 || code > 599 {
+			return errors.New("retry condition " + c + " must be a status code or " + connErrorRetry)
+		}
+	}
+	return nil
+}
+
+// Validates a duration flag or field. Empty strings are allowed.
+func validDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return errors.New(name + " must be a positive duration such as 500ms or 10s")
+	}
+	return nil
+}
+
+// Builds the retry policy for a call. Flags on the call take precedence over
+// the settings saved with the request. Requests that are not idempotent are
+// only retried on the conditions given explicitly.
+func newRetryPolicy(ctx *cli.Context, reqinfo *RequestInfo, req *http.Request) (*retryPolicy, error) {
+	p := &retryPolicy{
+		retries: reqinfo.Retries,
+		delay:   defaultRetryDelay,
+		codes:   make(map[int]bool),
+	}
+	if ctx.IsSet("retries") {
+		p.retries = ctx.Int("retries")
+	}
+	if p.retries < 0 {
+		return nil, errors.New("retries must not be negative")
+	}
+	delay := reqinfo.RetryDelay
+	if ctx.IsSet("retry-delay") {
+		delay = ctx.String("retry-delay")
+	}
+	if err := validDuration("retry delay", delay); err != nil {
+		return nil, err
+	}
+	if delay != "" {
+		p.delay, _ = time.ParseDuration(delay)
+	}
+	on := reqinfo.RetryOn
+	if ctx.IsSet("retry-on") {
+		on = splitList(ctx.StringSlice("retry-on"))
+	}
+	if len(on) == 0 && idempotent(req) {
+		on = defaultRetryOn
+	}
+	if err := validRetryOn(on); err != nil {
+		return nil, err
+	}
+	for _, c := range on {
+		if c == connErrorRetry {
+			p.conn = true
+			continue
+		}
+		code, _ := strconv.Atoi(c)
+		p.codes[code] = true
+	}
+	return p, nil
+}
+
+// TRUE if the outcome of an attempt warrants another one
+func (p *retryPolicy) shouldRetry(attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.retries {
+		return false
+	}
+	if err != nil {
+		return p.conn
+	}
+	return p.codes[resp.StatusCode]
+}
+
+// Exponential backoff with jitter. The returned delay is between half and all
+// of delay * 2^attempt, capped at maxRetryDelay. A delay of 0 never waits.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	if p.delay <= 0 {
+		return 0
+	}
+	d := p.delay << attempt
+	// the shift overflowed
+	if attempt >= 63 || d>>attempt != p.delay {
+		d = maxRetryDelay
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half+1)
+}
+
+// Waits before the next attempt, or returns early with the context's error.
+// Does not wait at all if the context's deadline would pass first.
+func (p *retryPolicy) wait(c context.Context, attempt int) error {
+	d := p.backoff(attempt)
+	if deadline, ok := c.Deadline(); ok && time.Until(deadline) <= d {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+// Returns the timeout for a call, or 0 if there is none
+func callTimeout(ctx *cli.Context, reqinfo *RequestInfo) (time.Duration, error) {
+	timeout := reqinfo.Timeout
+	if ctx.IsSet("timeout") {
+		timeout = ctx.String("timeout")
+	}
+	if err := validDuration("timeout", timeout); err != nil {
+		return 0, err
+	}
+	if timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(timeout)
+}
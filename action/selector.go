@@ -0,0 +1,314 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Selects parts of a response body. Expressions starting with '/' are XPath
+// and are evaluated against XML, everything else is a jq style path such as
+// .data.items[0].id evaluated against JSON. Each match is returned as a
+// string: JSON strings are unquoted and other JSON values are encoded.
+func selectFromBody(body []byte, expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "/") {
+		return selectXML(body, expr)
+	}
+	return selectJSON(body, expr)
+}
+
+func selectJSON(body []byte, expr string) ([]string, error) {
+	p, err := parsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := decodeJSON(string(body))
+	if !ok {
+		return nil, errors.New("response body is not valid JSON")
+	}
+	values := []any{v}
+	for _, s := range p {
+		var next []any
+		for _, v := range values {
+			next = append(next, selectSegment(v, s)...)
+		}
+		values = next
+	}
+	if len(values) == 0 {
+		return nil, errors.New("no match for " + expr)
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+			continue
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, errors.New("failed to encode selection")
+		}
+		out = append(out, string(b))
+	}
+	return out, nil
+}
+
+// Applies one path segment to a JSON value. Negative indexes count from the end.
+func selectSegment(v any, s pathSegment) []any {
+	switch {
+	case s.isIndex:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		if s.wildcard {
+			return arr
+		}
+		i := s.index
+		if i < 0 {
+			i += len(arr)
+		}
+		if i < 0 || i >= len(arr) {
+			return nil
+		}
+		return []any{arr[i]}
+	case s.wildcard:
+		switch obj := v.(type) {
+		case map[string]any:
+			out := make([]any, 0, len(obj))
+			for _, k := range sortedKeys(obj) {
+				out = append(out, obj[k])
+			}
+			return out
+		case []any:
+			return obj
+		}
+		return nil
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	child, ok := obj[s.key]
+	if !ok {
+		return nil
+	}
+	return []any{child}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// A minimal XML tree used to evaluate XPath
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+	isText   bool
+}
+
+func parseXMLTree(body []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("response body is not valid XML")
+		}
+		parent := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.children = append(parent.children, &xmlNode{text: string(t), isText: true})
+		}
+	}
+	return root, nil
+}
+
+// Concatenated text of the node and its descendants
+func (n *xmlNode) textContent() string {
+	if n.isText {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.textContent())
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmlNode) elements() []*xmlNode {
+	var out []*xmlNode
+	for _, c := range n.children {
+		if !c.isText {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (n *xmlNode) descendants() []*xmlNode {
+	var out []*xmlNode
+	for _, c := range n.elements() {
+		out = append(out, c)
+		out = append(out, c.descendants()...)
+	}
+	return out
+}
+
+var xpathStep = regexp.MustCompile(`^([\w.:-]+|\*|@[\w.:-]+|text\(\))((?:\[[^\]]+\])*)$`)
+var xpathPredicate = regexp.MustCompile(`\[([^\]]+)\]`)
+var xpathAttrPredicate = regexp.MustCompile(`^@([\w.:-]+)\s*=\s*(?:'([^']*)'|"([^"]*)")$`)
+
+// Evaluates a subset of XPath: absolute paths (/a/b), descendants (//b),
+// wildcards (*), positions ([1]), attribute filters ([@id='1']) and a final
+// @attr or text() step. Elements are returned as their text content.
+func selectXML(body []byte, expr string) ([]string, error) {
+	invalid := errors.New("invalid XPath " + expr)
+	root, err := parseXMLTree(body)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*xmlNode{root}
+	rest := expr
+	for rest != "" {
+		descendant := strings.HasPrefix(rest, "//")
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "/"), "/")
+		step := rest
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			step, rest = rest[:i], rest[i:]
+		} else {
+			rest = ""
+		}
+		m := xpathStep.FindStringSubmatch(step)
+		if m == nil {
+			return nil, invalid
+		}
+		name, predicates := m[1], xpathPredicate.FindAllStringSubmatch(m[2], -1)
+		// final @attr and text() steps produce strings
+		if strings.HasPrefix(name, "@") || name == "text()" {
+			if rest != "" || len(predicates) > 0 {
+				return nil, invalid
+			}
+			var out []string
+			for _, n := range nodes {
+				candidates := []*xmlNode{n}
+				if descendant {
+					candidates = append(candidates, n.descendants()...)
+				}
+				for _, c := range candidates {
+					if name == "text()" {
+						for _, child := range c.children {
+							if child.isText && strings.TrimSpace(child.text) != "" {
+								out = append(out, strings.TrimSpace(child.text))
+							}
+						}
+					} else if v, ok := c.attr(name[1:]); ok {
+						out = append(out, v)
+					}
+				}
+			}
+			if len(out) == 0 {
+				return nil, errors.New("no match for " + expr)
+			}
+			return out, nil
+		}
+		var next []*xmlNode
+		for _, n := range nodes {
+			candidates := n.elements()
+			if descendant {
+				candidates = n.descendants()
+			}
+			var matched []*xmlNode
+			for _, c := range candidates {
+				if name == "*" || c.name == name {
+					matched = append(matched, c)
+				}
+			}
+			for _, p := range predicates {
+				matched, err = applyXPathPredicate(matched, p[1])
+				if err != nil {
+					return nil, invalid
+				}
+			}
+			next = append(next, matched...)
+		}
+		nodes = next
+	}
+	if len(nodes) == 0 || len(nodes) == 1 && nodes[0] == root {
+		return nil, errors.New("no match for " + expr)
+	}
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.textContent())
+	}
+	return out, nil
+}
+
+func applyXPathPredicate(nodes []*xmlNode, predicate string) ([]*xmlNode, error) {
+	predicate = strings.TrimSpace(predicate)
+	if n, err := strconv.Atoi(predicate); err == nil {
+		if n < 1 || n > len(nodes) {
+			return nil, nil
+		}
+		return nodes[n-1 : n], nil
+	}
+	if predicate == "last()" {
+		if len(nodes) == 0 {
+			return nil, nil
+		}
+		return nodes[len(nodes)-1:], nil
+	}
+	m := xpathAttrPredicate.FindStringSubmatch(predicate)
+	if m == nil {
+		if strings.HasPrefix(predicate, "@") {
+			var out []*xmlNode
+			for _, n := range nodes {
+				if _, ok := n.attr(predicate[1:]); ok {
+					out = append(out, n)
+				}
+			}
+			return out, nil
+		}
+		return nil, errors.New("unsupported predicate " + predicate)
+	}
+	want := m[2] + m[3]
+	var out []*xmlNode
+	for _, n := range nodes {
+		if v, ok := n.attr(m[1]); ok && v == want {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,62 @@
+package action
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A saved response that later calls can be compared against
+type Snapshot struct {
+	Version    string    `yaml:"version"`
+	Timestamp  time.Time `yaml:"timestamp"`
+	Status     string    `yaml:"status"`
+	StatusCode int       `yaml:"status_code"`
+	Headers    []Header  `yaml:"headers,omitempty"`
+	Body       string    `yaml:"body"`
+	// paths that are skipped when comparing, e.g. .meta.timestamp
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+func SnapshotPath(cfgPath, app, req string) string {
+	return path.Join(cfgPath, "snapshots", app, req+".yml")
+}
+
+func WriteSnapshot(cfgPath, app, req string, snapshot *Snapshot) error {
+	data, err := yaml.Marshal(snapshot)
+	if err != nil {
+		return errors.New("failed to marshal data")
+	}
+	if err := os.MkdirAll(path.Dir(SnapshotPath(cfgPath, app, req)), 0700); err != nil {
+		return err
+	}
+	return writePrivateFile(SnapshotPath(cfgPath, app, req), data)
+}
+
+func ReadSnapshot(cfgPath, app, req string) (*Snapshot, error) {
+	contents, err := os.ReadFile(SnapshotPath(cfgPath, app, req))
+	if err != nil {
+		return nil, errors.New("request " + req + " does not have a snapshot")
+	}
+	snapshot := new(Snapshot)
+	if err := yaml.Unmarshal(contents, snapshot); err != nil {
+		return nil, errors.New("snapshot is malformed or corrupted")
+	}
+	return snapshot, nil
+}
+
+func newSnapshot(resp *http.Response, body []byte, ignore []string) *Snapshot {
+	return &Snapshot{
+		Version:    "1",
+		Timestamp:  time.Now(),
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Headers:    headerList(resp.Header),
+		Body:       string(body),
+		Ignore:     ignore,
+	}
+}
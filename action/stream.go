@@ -0,0 +1,137 @@
+package action
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// A Server-Sent Event. Data that is valid JSON is embedded as JSON, anything
+// else as a string.
+type StreamEvent struct {
+	Event string `json:"event"`
+	ID    string `json:"id,omitempty"`
+	Data  any    `json:"data"`
+}
+
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// Copies a streamed response body to w as it arrives and returns the number of
+// events seen. Server-Sent Events are printed one JSON object per line unless
+// raw is set, other bodies are copied untouched and every line counts as an
+// event. Streaming stops after maxEvents events if it is positive. Running
+// into the deadline of the request's context is not an error.
+func streamBody(body io.Reader, contentType string, w io.Writer, raw bool, maxEvents int) (int, error) {
+	var (
+		n   int
+		err error
+	)
+	if isEventStream(contentType) && !raw {
+		n, err = streamEvents(body, w, maxEvents)
+	} else {
+		n, err = streamLines(body, w, maxEvents)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return n, nil
+	}
+	return n, err
+}
+
+// Copies r to w chunk by chunk, stopping after the maxEvents'th newline
+func streamLines(r io.Reader, w io.Writer, maxEvents int) (int, error) {
+	buf := make([]byte, 32<<10)
+	lines := 0
+	for {
+		n, err := r.Read(buf)
+		chunk := buf[:n]
+		if maxEvents > 0 {
+			for i, c := range chunk {
+				if c != '\n' {
+					continue
+				}
+				lines++
+				if lines == maxEvents {
+					w.Write(chunk[:i+1])
+					return lines, nil
+				}
+			}
+		}
+		w.Write(chunk)
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// Parses Server-Sent Events as described in
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+func streamEvents(r io.Reader, w io.Writer, maxEvents int) (int, error) {
+	var (
+		scanner = bufio.NewScanner(r)
+		event   string
+		lastID  string
+		data    []string
+		count   int
+	)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	dispatch := func() error {
+		defer func() { event, data = "", nil }()
+		if data == nil {
+			return nil
+		}
+		e := StreamEvent{Event: event, ID: lastID, Data: strings.Join(data, "\n")}
+		if e.Event == "" {
+			e.Event = "message"
+		}
+		if joined := []byte(e.Data.(string)); json.Valid(joined) {
+			e.Data = json.RawMessage(joined)
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return errors.New("failed to encode event")
+		}
+		fmt.Fprintln(w, string(b))
+		count++
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return count, err
+			}
+			if maxEvents > 0 && count >= maxEvents {
+				return count, nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment, often used as a keep-alive
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				lastID = value
+			}
+		}
+	}
+	return count, scanner.Err()
+}
@@ -0,0 +1,128 @@
+package action
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Functions available in templates, evaluated every time a template is
+// rendered, e.g. {{uuid}} or {{now "2006-01-02"}}
+var templateFuncs = template.FuncMap{
+	"uuid": func() string {
+		b := make([]byte, 16)
+		rand.Read(b)
+		// version 4, variant 10
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	},
+	// formats the current time with a Go layout, RFC 3339 by default
+	"now": func(layout ...string) (string, error) {
+		if len(layout) > 1 {
+			return "", errors.New("now takes at most one layout")
+		}
+		if len(layout) == 0 {
+			return time.Now().Format(time.RFC3339), nil
+		}
+		return time.Now().Format(layout[0]), nil
+	},
+	"unix": func() int64 {
+		return time.Now().Unix()
+	},
+	// a random integer between min and max, inclusive
+	"randomInt": func(min, max int) (int64, error) {
+		if max < min {
+			return 0, errors.New("randomInt needs a maximum of at least its minimum")
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(max)-int64(min)+1))
+		if err != nil {
+			return 0, err
+		}
+		return n.Int64() + int64(min), nil
+	},
+	"randomEmail": func() string {
+		b := make([]byte, 6)
+		rand.Read(b)
+		return "user-" + hex.EncodeToString(b) + "@example.com"
+	},
+	"env": func(name string) (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.New("environment variable " + name + " is not set")
+		}
+		return v, nil
+	},
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	// hex encoded SHA-256 digest
+	"sha256": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	// a JSON string literal, quotes included
+	"json": func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	},
+	"file": func(name string) (string, error) {
+		contents, err := os.ReadFile(name)
+		if err != nil {
+			return "", errors.New("failed to read file " + name)
+		}
+		return string(contents), nil
+	},
+}
+
+// Renders text as a Go template with vars available as {{.name}} along with
+// templateFuncs. Text that does not contain any actions is returned untouched.
+func render(text string, vars map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.New("invalid template " + text + ": " + strings.TrimPrefix(err.Error(), "template: :"))
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", errors.New("failed to render " + text + ": " + strings.TrimPrefix(err.Error(), "template: :"))
+	}
+	return b.String(), nil
+}
+
+// Parses name=value pairs. Repeated names keep every value in order.
+func parseKeyValues(raw []string) (Query, error) {
+	out := make(Query)
+	for _, kv := range raw {
+		k, v, found := strings.Cut(kv, "=")
+		if !found || k == "" {
+			return nil, errors.New("malformed pair " + kv + ", expected name=value")
+		}
+		out[k] = append(out[k], v)
+	}
+	return out, nil
+}
+
+// Parses --var flags into template variables. Later values win.
+func parseVars(raw []string) (map[string]string, error) {
+	pairs, err := parseKeyValues(raw)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(pairs))
+	for k, v := range pairs {
+		vars[k] = v[len(v)-1]
+	}
+	return vars, nil
+}
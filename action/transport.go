@@ -0,0 +1,198 @@
+package action
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TRUE if version is a TLS version sp9rk knows how to enforce, or empty
+func validTLSVersion(version string) bool {
+	if version == "" {
+		return true
+	}
+	_, ok := tlsVersions[version]
+	return ok
+}
+
+// Builds the TLS configuration described by the app. Returns nil if the app
+// does not change any of the defaults.
+func tlsConfig(info TLSInfo, insecure bool) (*tls.Config, error) {
+	if info == (TLSInfo{}) && !insecure {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		ServerName:         info.ServerName,
+		InsecureSkipVerify: info.Insecure || insecure,
+	}
+	if info.MinVersion != "" {
+		v, ok := tlsVersions[info.MinVersion]
+		if !ok {
+			return nil, errors.New("unsupported minimum TLS version " + info.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+	if info.CACert != "" {
+		pem, err := os.ReadFile(info.CACert)
+		if err != nil {
+			return nil, errors.New("failed to read CA bundle " + info.CACert)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA bundle " + info.CACert + " contains no certificates")
+		}
+		cfg.RootCAs = pool
+	}
+	if info.ClientCert != "" {
+		// the key may live in the same PEM file as the certificate
+		key := info.ClientKey
+		if key == "" {
+			key = info.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(info.ClientCert, key)
+		if err != nil {
+			return nil, errors.New("failed to load client certificate: " + err.Error())
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	} else if info.ClientKey != "" {
+		return nil, errors.New("client key specified without a client certificate")
+	}
+	return cfg, nil
+}
+
+// Returns nil if raw is empty
+func parseProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, errors.New("proxy URL is invalid")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.New("proxy scheme must be one of http, https or socks5")
+	}
+	return u, nil
+}
+
+// TRUE if the host (with or without a port) matches an entry in the no proxy
+// list. Entries may be a hostname, which also matches subdomains, a domain
+// suffix starting with '.', an IP address, a CIDR range or '*'.
+func bypassProxy(noProxy []string, hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case entry == hostport || entry == host:
+			return true
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case strings.HasSuffix(host, "."+entry):
+			return true
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip := net.ParseIP(host); ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Picks the proxy for a call. A --proxy flag wins over the app's proxy, which
+// wins over the global configuration. Returns nil if none of them set a proxy,
+// in which case the environment is used.
+func proxyFunc(ctx *cli.Context, cfg *Config, appinfo *AppInfo) (func(*http.Request) (*url.URL, error), error) {
+	if ctx.String("proxy") != "" {
+		u, err := parseProxyURL(ctx.String("proxy"))
+		if err != nil {
+			return nil, err
+		}
+		return http.ProxyURL(u), nil
+	}
+	info := appinfo.Proxy
+	if info.URL == "" {
+		info.URL = cfg.Proxy.URL
+	}
+	info.NoProxy = append(append([]string{}, appinfo.Proxy.NoProxy...), cfg.Proxy.NoProxy...)
+	u, err := parseProxyURL(info.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		if len(info.NoProxy) == 0 {
+			return nil, nil
+		}
+		return func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(info.NoProxy, req.URL.Host) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}, nil
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(info.NoProxy, req.URL.Host) {
+			return nil, nil
+		}
+		return u, nil
+	}, nil
+}
+
+// Returns a transport for calls made against the app. The base transport is
+// cloned so settings never leak between apps. Custom round trippers (such as
+// those used in tests) are returned untouched.
+func newTransport(cfgPath string, ctx *cli.Context, base http.RoundTripper, appinfo *AppInfo) (http.RoundTripper, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tr, ok := base.(*http.Transport)
+	if !ok {
+		return base, nil
+	}
+	tr = tr.Clone()
+	tlscfg, err := tlsConfig(appinfo.TLS, ctx.Bool("insecure"))
+	if err != nil {
+		return nil, err
+	}
+	if tlscfg != nil {
+		tr.TLSClientConfig = tlscfg
+	}
+	cfg, err := ReadConfig(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc(ctx, cfg, appinfo)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		tr.Proxy = proxy
+	}
+	return tr, nil
+}
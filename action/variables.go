@@ -0,0 +1,61 @@
+package action
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// guards the variables files against concurrent calls capturing at once
+var variablesMu sync.Mutex
+
+// Variables captured from responses are stored per application and are
+// available to every later call as template variables.
+func VariablesPath(cfgPath, app string) string {
+	return path.Join(cfgPath, "variables", app+".yml")
+}
+
+// Returns the stored variables of an application, or none if nothing has been
+// stored yet
+func ReadVariables(cfgPath, app string) (map[string]string, error) {
+	vars := make(map[string]string)
+	contents, err := os.ReadFile(VariablesPath(cfgPath, app))
+	if errors.Is(err, os.ErrNotExist) {
+		return vars, nil
+	}
+	if err != nil {
+		return nil, errors.New("failed to read variables")
+	}
+	if err := yaml.Unmarshal(contents, &vars); err != nil {
+		return nil, errors.New("variables file is malformed or corrupted")
+	}
+	return vars, nil
+}
+
+func WriteVariables(cfgPath, app string, vars map[string]string) error {
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return errors.New("failed to marshal data")
+	}
+	if err := os.MkdirAll(path.Dir(VariablesPath(cfgPath, app)), 0700); err != nil {
+		return err
+	}
+	return writePrivateFile(VariablesPath(cfgPath, app), data)
+}
+
+// Adds vars to the application's stored variables, replacing existing values
+func storeVariables(cfgPath, app string, vars map[string]string) error {
+	variablesMu.Lock()
+	defer variablesMu.Unlock()
+	stored, err := ReadVariables(cfgPath, app)
+	if err != nil {
+		return err
+	}
+	for k, v := range vars {
+		stored[k] = v
+	}
+	return WriteVariables(cfgPath, app, stored)
+}
@@ -0,0 +1,291 @@
+package action
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/net/websocket"
+)
+
+// messages larger than this are rejected
+const maxWebSocketMessage = 16 << 20
+
+// normal closure, see https://www.rfc-editor.org/rfc/rfc6455#section-7.4.1
+const wsCloseNormal = 1000
+
+// flags of call that only apply to a single HTTP exchange
+var webSocketIgnoredFlags = []string{
+	"fail", "no-redirect", "snapshot", "compare", "ignore", "output-file", "remote-name",
+	"continue", "stream", "select", "retries", "retry-on", "retry-delay",
+}
+
+// Rejects flags and request settings a WebSocket call would otherwise ignore
+func checkWebSocketCall(ctx *cli.Context, reqinfo *RequestInfo) error {
+	for _, name := range webSocketIgnoredFlags {
+		if ctx.IsSet(name) {
+			return errors.New("--" + name + " cannot be used with websocket requests")
+		}
+	}
+	if len(reqinfo.Assert) > 0 || len(reqinfo.Capture) > 0 || reqinfo.Hooks != (Hooks{}) {
+		return errors.New("websocket requests cannot have assertions, captures or hooks")
+	}
+	return nil
+}
+
+// A message received over a WebSocket
+type wsMessage struct {
+	data   []byte
+	binary bool
+}
+
+// Receives messages into a *wsMessage, keeping track of whether they are text
+// or binary
+var wsMessageCodec = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		m := v.(*wsMessage)
+		m.data, m.binary = data, payloadType == websocket.BinaryFrame
+		return nil
+	},
+}
+
+// Returns the proxy a websocket or grpc connection to u goes through, if any.
+// The proxy settings of every other call apply, choosing by the scheme of the
+// equivalent http request.
+func tunnelProxy(cfgPath string, ctx *cli.Context, appinfo *AppInfo, u *url.URL) (*url.URL, error) {
+	cfg, err := ReadConfig(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyFunc(ctx, cfg, appinfo)
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	target := *u
+	switch target.Scheme {
+	case "ws", "grpc":
+		target.Scheme = "http"
+	case "wss", "grpcs":
+		target.Scheme = "https"
+	}
+	return proxy(&http.Request{URL: &target, Header: make(http.Header)})
+}
+
+var errTunnelProxy = errors.New("websocket and grpc requests can only use http and https proxies")
+
+// Connects to addr, through a tunnel opened with CONNECT when proxy is set.
+// Only http and https proxies can open tunnels.
+func dialTunnel(addr string, proxy *url.URL, insecure bool, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if proxy == nil {
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, errors.New("failed to connect to " + addr)
+		}
+		return conn, nil
+	}
+	proxyAddr := proxy.Host
+	switch {
+	case proxy.Scheme != "http" && proxy.Scheme != "https":
+		return nil, errTunnelProxy
+	case proxy.Port() == "" && proxy.Scheme == "https":
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), "443")
+	case proxy.Port() == "":
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), "80")
+	}
+	conn, err := dialer.Dial("tcp", proxyAddr)
+	if err != nil {
+		return nil, errors.New("failed to connect to proxy " + proxyAddr)
+	}
+	if proxy.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname(), InsecureSkipVerify: insecure})
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	connect := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := proxy.User.Username() + ":" + password
+		connect.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	if err := connect.Write(conn); err != nil {
+		conn.Close()
+		return nil, errors.New("failed to send CONNECT to proxy " + proxyAddr)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connect)
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("failed to read the response of proxy " + proxyAddr)
+	}
+	// the body of a successful CONNECT is the tunnel itself, so it is left
+	// unread
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.New("proxy refused to connect to " + addr + ": " + resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// Opens a WebSocket using the URL and headers of req, through proxy if it is
+// set. http and ws hosts are dialed in plain text, https and wss hosts over
+// TLS. The handshake and framing are left to golang.org/x/net/websocket.
+func dialWebSocket(req *http.Request, info TLSInfo, insecure bool, timeout time.Duration, proxy *url.URL) (*websocket.Conn, error) {
+	secure := false
+	origin := *req.URL
+	origin.Path, origin.RawPath, origin.RawQuery = "", "", ""
+	switch req.URL.Scheme {
+	case "http", "ws":
+		req.URL.Scheme, origin.Scheme = "ws", "http"
+	case "https", "wss":
+		req.URL.Scheme, origin.Scheme = "wss", "https"
+		secure = true
+	default:
+		return nil, errors.New("unsupported websocket scheme " + req.URL.Scheme)
+	}
+	config, err := websocket.NewConfig(req.URL.String(), origin.String())
+	if err != nil {
+		return nil, errors.New("websocket URL " + req.URL.String() + " is invalid")
+	}
+	config.Header = req.Header
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(req.URL.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(req.URL.Hostname(), "80")
+		}
+	}
+	conn, err := dialTunnel(addr, proxy, insecure, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		cfg, err := tlsConfig(info, insecure)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = req.URL.Hostname()
+		}
+		conn = tls.Client(conn, cfg)
+	}
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if errors.Is(err, websocket.ErrBadStatus) {
+		conn.Close()
+		return nil, errors.New("server refused the websocket")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: " + err.Error())
+	}
+	conn.SetDeadline(time.Time{})
+	ws.MaxPayloadBytes = maxWebSocketMessage
+	return ws, nil
+}
+
+// Opens the request's WebSocket, sends its messages and prints every message
+// received until --max-events messages arrived, --stream-timeout passed or the
+// server closed the connection. With --interactive every line read from stdin
+// is sent as well, and the connection is closed at the end of input.
+func callWebSocket(cfgPath string, ctx *cli.Context, stdout io.Writer, appinfo *AppInfo, reqinfo *RequestInfo, vars map[string]string) error {
+	if err := checkWebSocketCall(ctx, reqinfo); err != nil {
+		return err
+	}
+	if err := validDuration("stream-timeout", ctx.String("stream-timeout")); err != nil {
+		return err
+	}
+	timeout, err := callTimeout(ctx, reqinfo)
+	if err != nil {
+		return err
+	}
+	req, err := newRequest(appinfo, reqinfo, vars)
+	if err != nil {
+		return err
+	}
+	proxy, err := tunnelProxy(cfgPath, ctx, appinfo, req.URL)
+	if err != nil {
+		return err
+	}
+	ws, err := dialWebSocket(req, appinfo.TLS, ctx.Bool("insecure"), timeout, proxy)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	for _, m := range reqinfo.Messages {
+		message, err := render(m, vars)
+		if err != nil {
+			return err
+		}
+		if err := websocket.Message.Send(ws, message); err != nil {
+			return errors.New("failed to send websocket message")
+		}
+		if ctx.Bool("verbose") {
+			fmt.Fprintln(stdout, "> "+message)
+		}
+	}
+	if ctx.String("stream-timeout") != "" {
+		d, _ := time.ParseDuration(ctx.String("stream-timeout"))
+		ws.SetReadDeadline(time.Now().Add(d))
+	}
+	if ctx.Bool("interactive") {
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				if err := websocket.Message.Send(ws, scanner.Text()); err != nil {
+					return
+				}
+			}
+			// the server ends the connection once it answers
+			ws.WriteClose(wsCloseNormal)
+		}()
+	}
+	for received := 0; ctx.Int("max-events") <= 0 || received < ctx.Int("max-events"); received++ {
+		var message wsMessage
+		err := wsMessageCodec.Receive(ws, &message)
+		if errors.Is(err, io.EOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if errors.Is(err, websocket.ErrFrameTooLarge) {
+			return errors.New("websocket message is too large")
+		}
+		if err != nil {
+			return errors.New("websocket connection failed: " + err.Error())
+		}
+		prefix := ""
+		if ctx.Bool("verbose") {
+			prefix = "< "
+		}
+		if message.binary {
+			fmt.Fprintf(stdout, "%s[binary message, %d bytes]\n", prefix, len(message.data))
+			continue
+		}
+		fmt.Fprintln(stdout, prefix+string(message.data))
+	}
+	return nil
+}
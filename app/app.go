@@ -48,11 +48,213 @@ func New(cfgPath string, httpClient http.Client) *cli.App {
 	verboseFlag := []string{"verbose", "v"}
 	noRedirectFlag := []string{"no-redirect", "n"}
 	failFlag := []string{"fail", "f"}
+	insecureFlag := []string{"insecure", "k"}
+	queryFlag := []string{"query", "q"}
+	varFlag := "var"
+	ignoreFlag := "ignore"
+
+	// output settings are shared between call and history replay
+	outputFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "raw",
+				Usage: "print the response body exactly as it was received",
+			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "do not colorize the response body (also disabled by NO_COLOR)",
+			},
+		}
+	}
+
+	// proxy settings are shared between create app, edit app and config
+	proxyFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringFlag{
+				Name:  "proxy",
+				Usage: "route requests through a proxy (http, https or socks5 URL)",
+			},
+			&cli.StringFlag{
+				Name:  "proxy-user",
+				Usage: "credentials for the proxy in the form user:password",
+			},
+			&cli.StringSliceFlag{
+				Name:  "no-proxy",
+				Usage: "hosts, domains or CIDR ranges that should not use the proxy",
+			},
+		}
+	}
+
+	// timeout and retry settings are shared between create req, edit req and call
+	retryFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringFlag{
+				Name:  "timeout",
+				Usage: "give up on the request, including retries, after this long (e.g. 10s)",
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Usage: "number of times to retry a failed request",
+			},
+			&cli.StringSliceFlag{
+				Name:        "retry-on",
+				Usage:       "status codes and/or conn-error that trigger a retry",
+				DefaultText: "502,503,504,conn-error for idempotent methods",
+			},
+			&cli.StringFlag{
+				Name:        "retry-delay",
+				Usage:       "initial delay between retries, doubled after each attempt",
+				DefaultText: "500ms",
+			},
+		}
+	}
+
+	// example responses served by sp9rk mock, shared between create req and edit req
+	exampleFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.IntFlag{
+				Name:        "example-status",
+				Usage:       "status code of the example response",
+				DefaultText: "200",
+			},
+			&cli.StringFlag{
+				Name:  "example-body",
+				Usage: "body of the example response",
+			},
+			&cli.StringSliceFlag{
+				Name:  "example-header",
+				Usage: "replace the example response's headers, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "example-latency",
+				Usage: "wait this long before sending the example response (e.g. 200ms)",
+			},
+		}
+	}
+
+	// hook commands are shared between creating and editing apps and requests
+	hookFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringFlag{
+				Name:  "pre-request",
+				Usage: "shell command that may change every request, see the README for its input and output",
+			},
+			&cli.StringFlag{
+				Name:  "post-response",
+				Usage: "shell command that may check every response and set variables",
+			},
+		}
+	}
+
+	// request defaults are shared between create app and edit app
+	defaultsFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "default-header",
+				Usage: "send a header with every request in the form \"Name: value\", may be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "default-query",
+				Usage: "send a query parameter with every request in the form name=value, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "default-timeout",
+				Usage: "timeout of requests that do not set one, e.g. 10s",
+			},
+			&cli.StringFlag{
+				Name:  "auth-basic",
+				Usage: "send basic auth credentials in the form username:password with every request",
+			},
+			&cli.StringFlag{
+				Name:  "auth-bearer",
+				Usage: "send a bearer token with every request",
+			},
+		}
+	}
+
+	// opting out of request defaults is shared between create req and edit req
+	unsetFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "unset-header",
+				Usage: "do not send the application's default header with the given name",
+			},
+			&cli.StringSliceFlag{
+				Name:  "unset-query",
+				Usage: "do not send the application's default query parameter with the given name",
+			},
+		}
+	}
+
+	// gRPC settings are shared between create app and edit app
+	grpcFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "proto",
+				Usage: ".proto files describing the app's gRPC services, server reflection is used without them",
+			},
+			&cli.StringSliceFlag{
+				Name:  "import-path",
+				Usage: "directories that imports in the .proto files are relative to",
+			},
+		}
+	}
+
+	// TLS settings are shared between create app and edit app
+	tlsFlags := func() []cli.Flag {
+		return []cli.Flag{
+			&cli.StringFlag{
+				Name:  "ca-cert",
+				Usage: "path to a PEM encoded CA bundle used to verify the host",
+			},
+			&cli.StringFlag{
+				Name:  "cert",
+				Usage: "path to a PEM encoded client certificate",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "path to the PEM encoded private key for the client certificate",
+			},
+			&cli.StringFlag{
+				Name:  "server-name",
+				Usage: "override the server name used to verify the host's certificate",
+			},
+			&cli.StringFlag{
+				Name:  "tls-min-version",
+				Usage: "minimum TLS version to accept (1.0, 1.1, 1.2 or 1.3)",
+			},
+			&cli.BoolFlag{
+				Name:    insecureFlag[0],
+				Aliases: insecureFlag[1:],
+				Usage:   "skip verification of the host's certificate",
+			},
+		}
+	}
+
+	// settings of an app, shared between create app and edit app
+	appSettingsFlags := func() []cli.Flag {
+		flags := tlsFlags()
+		flags = append(flags, proxyFlags()...)
+		flags = append(flags, grpcFlags()...)
+		flags = append(flags, hookFlags()...)
+		return append(flags, defaultsFlags()...)
+	}
+
+	// settings of a request, shared between create req and edit req
+	requestSettingsFlags := func() []cli.Flag {
+		flags := retryFlags()
+		flags = append(flags, exampleFlags()...)
+		flags = append(flags, hookFlags()...)
+		return append(flags, unsetFlags()...)
+	}
 
 	return &cli.App{
 		Name:    "sp9rk",
 		Usage:   "Automate your API calls in the command line",
 		Version: "v0.0.1",
+		// header and query values often contain commas, so slice flags are
+		// never split. Flags that take lists split them themselves.
+		DisableSliceFlagSeparator: true,
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:  debugFlag,
@@ -79,7 +281,7 @@ Type 'sp9rk help' for more information.`)
 					{
 						Name:  "app",
 						Usage: "create an application",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:    descriptionFlag[0],
 								Aliases: descriptionFlag[1:],
@@ -91,14 +293,14 @@ Type 'sp9rk help' for more information.`)
 								Usage:   "specify the application's host address",
 								Value:   "http://localhost",
 							},
-						},
+						}, appSettingsFlags()...),
 						Action: action.CreateApplication(cfgPath),
 					},
 					{
 						Name:    "request",
 						Aliases: []string{"req"},
 						Usage:   "create a request within an application",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:    appFlag[0],
 								Aliases: appFlag[1:],
@@ -131,9 +333,77 @@ Type 'sp9rk help' for more information.`)
 								Aliases: headerFlag[1:],
 								Usage:   "",
 							},
-						},
+							&cli.StringSliceFlag{
+								Name:    queryFlag[0],
+								Aliases: queryFlag[1:],
+								Usage:   "set a query parameter in the form name=value, may be repeated",
+							},
+							&cli.StringFlag{
+								Name:        "kind",
+								Usage:       "the kind of request: http, websocket, graphql or grpc",
+								DefaultText: "http",
+							},
+							&cli.StringFlag{
+								Name:  "rpc",
+								Usage: "full name of the method a grpc request calls, e.g. grpc.health.v1.Health/Check",
+							},
+							&cli.StringSliceFlag{
+								Name:  "message",
+								Usage: "a message to send once a websocket is open, may be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "document",
+								Usage: "the GraphQL document of a graphql request",
+							},
+							&cli.StringFlag{
+								Name:  "document-file",
+								Usage: "read the GraphQL document from a file on every call",
+							},
+							&cli.StringFlag{
+								Name:  "operation",
+								Usage: "the GraphQL operation to run when the document has several",
+							},
+							&cli.StringFlag{
+								Name:  "variables",
+								Usage: "GraphQL variables as a JSON object, may use template variables",
+							},
+							&cli.StringSliceFlag{
+								Name:  "assert",
+								Usage: "check every response, e.g. '.data.id == 42', 'status != 500' or '.name ~= ^a', may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "capture",
+								Usage: "save a value from every response as a variable in the form name=.path.to.value",
+							},
+						}, requestSettingsFlags()...),
 						Action: action.CreateRequest(cfgPath),
 					},
+					{
+						Name:  "group",
+						Usage: "create a group of requests within an application",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    appFlag[0],
+								Aliases: appFlag[1:],
+								Usage:   "",
+							},
+							&cli.StringFlag{
+								Name:    descriptionFlag[0],
+								Aliases: descriptionFlag[1:],
+								Usage:   "",
+							},
+							&cli.StringSliceFlag{
+								Name:    headerFlag[0],
+								Aliases: headerFlag[1:],
+								Usage:   "send a header with every request of the group, may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "var",
+								Usage: "set a variable for every request of the group in the form name=value, may be repeated",
+							},
+						},
+						Action: action.CreateGroup(cfgPath),
+					},
 				},
 			},
 			{
@@ -143,7 +413,7 @@ Type 'sp9rk help' for more information.`)
 					{
 						Name:  "app",
 						Usage: "edit an application",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:    descriptionFlag[0],
 								Aliases: descriptionFlag[1:],
@@ -153,16 +423,27 @@ Type 'sp9rk help' for more information.`)
 								Name:    hostFlag[0],
 								Aliases: hostFlag[1:],
 								Usage:   "specify the application's host address",
-								Value:   "http://localhost",
 							},
-						},
+							&cli.StringSliceFlag{
+								Name:  "remove-default-header",
+								Usage: "stop sending the default header with the given name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-default-query",
+								Usage: "stop sending the default query parameter with the given name",
+							},
+							&cli.BoolFlag{
+								Name:  "clear-auth",
+								Usage: "stop sending auth credentials",
+							},
+						}, appSettingsFlags()...),
 						Action: action.EditApplication(cfgPath),
 					},
 					{
 						Name:    "request",
 						Aliases: []string{"req"},
 						Usage:   "edit a request within an application",
-						Flags: []cli.Flag{
+						Flags: append([]cli.Flag{
 							&cli.StringFlag{
 								Name:    appFlag[0],
 								Aliases: appFlag[1:],
@@ -193,9 +474,129 @@ Type 'sp9rk help' for more information.`)
 								Aliases: headerFlag[1:],
 								Usage:   "",
 							},
-						},
+							&cli.StringSliceFlag{
+								Name:    queryFlag[0],
+								Aliases: queryFlag[1:],
+								Usage:   "set a query parameter in the form name=value, may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "add-header",
+								Usage: "add a header, keeping existing headers with the same name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "set-header",
+								Usage: "set a header, replacing existing headers with the same name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-header",
+								Usage: "remove every header with the given name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-query",
+								Usage: "remove every value of the given query parameter",
+							},
+							&cli.StringFlag{
+								Name:  "kind",
+								Usage: "the kind of request: http, websocket, graphql or grpc",
+							},
+							&cli.StringFlag{
+								Name:  "rpc",
+								Usage: "full name of the method a grpc request calls, e.g. grpc.health.v1.Health/Check",
+							},
+							&cli.StringSliceFlag{
+								Name:  "message",
+								Usage: "a message to send once a websocket is open, may be repeated",
+							},
+							&cli.StringFlag{
+								Name:  "document",
+								Usage: "the GraphQL document of a graphql request",
+							},
+							&cli.StringFlag{
+								Name:  "document-file",
+								Usage: "read the GraphQL document from a file on every call",
+							},
+							&cli.StringFlag{
+								Name:  "operation",
+								Usage: "the GraphQL operation to run when the document has several",
+							},
+							&cli.StringFlag{
+								Name:  "variables",
+								Usage: "GraphQL variables as a JSON object, may use template variables",
+							},
+							&cli.StringSliceFlag{
+								Name:  "assert",
+								Usage: "replace the request's assertions, e.g. '.data.id == 42', 'status != 500' or '.name ~= ^a', may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "capture",
+								Usage: "save a value from every response as a variable in the form name=.path.to.value",
+							},
+							&cli.BoolFlag{
+								Name:  "clear-asserts",
+								Usage: "remove every assertion",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-capture",
+								Usage: "stop capturing the given variable",
+							},
+							&cli.BoolFlag{
+								Name:    "interactive",
+								Aliases: []string{"i"},
+								Usage:   "open the request in $EDITOR",
+							},
+							&cli.StringSliceFlag{
+								Name:  "inherit-header",
+								Usage: "send the application's default header with the given name again",
+							},
+							&cli.StringSliceFlag{
+								Name:  "inherit-query",
+								Usage: "send the application's default query parameter with the given name again",
+							},
+						}, requestSettingsFlags()...),
 						Action: action.EditRequest(cfgPath),
 					},
+					{
+						Name:  "group",
+						Usage: "edit a group of requests",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    appFlag[0],
+								Aliases: appFlag[1:],
+								Usage:   "",
+							},
+							&cli.StringFlag{
+								Name:    descriptionFlag[0],
+								Aliases: descriptionFlag[1:],
+								Usage:   "",
+							},
+							&cli.StringSliceFlag{
+								Name:    headerFlag[0],
+								Aliases: headerFlag[1:],
+								Usage:   "replace the group's headers, may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "add-header",
+								Usage: "add a header, keeping existing headers with the same name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "set-header",
+								Usage: "set a header, replacing existing headers with the same name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-header",
+								Usage: "remove every header with the given name",
+							},
+							&cli.StringSliceFlag{
+								Name:  "var",
+								Usage: "set a variable for every request of the group in the form name=value, may be repeated",
+							},
+							&cli.StringSliceFlag{
+								Name:  "remove-var",
+								Usage: "remove the group's variable with the given name",
+							},
+						},
+						Action: action.EditGroup(cfgPath),
+					},
 				},
 			},
 			{
@@ -230,6 +631,22 @@ Type 'sp9rk help' for more information.`)
 						},
 						Action: action.DeleteRequest(cfgPath),
 					},
+					{
+						Name:  "group",
+						Usage: "delete a group and every request in it",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  confirmFlag,
+								Usage: "skips the confirmation request and immediately deletes the resource",
+							},
+							&cli.StringFlag{
+								Name:    appFlag[0],
+								Aliases: appFlag[1:],
+								Usage:   "specify an application",
+							},
+						},
+						Action: action.DeleteGroup(cfgPath),
+					},
 				},
 			},
 			{
@@ -276,6 +693,10 @@ Type 'sp9rk help' for more information.`)
 								Aliases: appFlag[1:],
 								Usage:   "specify an application",
 							},
+							&cli.BoolFlag{
+								Name:  "resolved",
+								Usage: "show the request with the application's defaults merged in",
+							},
 						},
 						Action: action.InfoRequest(cfgPath),
 					},
@@ -287,9 +708,231 @@ Type 'sp9rk help' for more information.`)
 				Action: action.Switch(cfgPath),
 			},
 			{
-				Name:  "call",
-				Usage: "make a request",
+				Name:  "config",
+				Usage: "view or change global settings",
+				Flags: append(proxyFlags(),
+					&cli.IntFlag{
+						Name:        "history-limit",
+						Usage:       "number of calls to keep in the history, or -1 to disable it",
+						DefaultText: "100",
+					},
+					&cli.BoolFlag{
+						Name:  "history-values",
+						Usage: "save the values of variables and query parameters in the history instead of redacting them",
+					},
+				),
+				Action: action.Configure(cfgPath),
+			},
+			{
+				Name:  "import",
+				Usage: "create requests from an API description",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "graphql-schema",
+						Usage: "create a request for every query and mutation of a GraphQL server",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    appFlag[0],
+								Aliases: appFlag[1:],
+								Usage:   "specify an application",
+							},
+							&cli.StringFlag{
+								Name:    pathFlag[0],
+								Aliases: pathFlag[1:],
+								Value:   "/graphql",
+								Usage:   "path of the GraphQL endpoint",
+							},
+							&cli.StringSliceFlag{
+								Name:    headerFlag[0],
+								Aliases: headerFlag[1:],
+								Usage:   "send a header with the introspection query and every created request",
+							},
+						},
+						Action: action.ImportGraphQLSchema(cfgPath, httpClient),
+					},
+				},
+			},
+			{
+				Name:  "vars",
+				Usage: "view or change the variables captured for an application",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    appFlag[0],
+						Aliases: appFlag[1:],
+						Usage:   "specify an application",
+					},
+					&cli.StringSliceFlag{
+						Name:  "set",
+						Usage: "set a variable in the form name=value, may be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "unset",
+						Usage: "remove a variable, may be repeated",
+					},
+				},
+				Action: action.Variables(cfgPath),
+			},
+			{
+				Name:      "bench",
+				Usage:     "send a request many times and report throughput and latency",
+				ArgsUsage: "<request>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    appFlag[0],
+						Aliases: appFlag[1:],
+						Usage:   "specify an application",
+					},
+					&cli.IntFlag{
+						Name:    "concurrency",
+						Aliases: []string{"c"},
+						Value:   10,
+						Usage:   "number of requests in flight at once",
+					},
+					&cli.IntFlag{
+						Name:        "requests",
+						Aliases:     []string{"n"},
+						Usage:       "number of requests to send",
+						DefaultText: "200 unless --duration is set",
+					},
+					&cli.StringFlag{
+						Name:  "duration",
+						Usage: "stop sending requests after this long (e.g. 30s)",
+					},
+					&cli.StringFlag{
+						Name:  "rate",
+						Usage: "limit how often requests start, e.g. 200/s or 30/m",
+					},
+					&cli.StringFlag{
+						Name:  "timeout",
+						Usage: "give up on each request after this long (e.g. 10s)",
+					},
+					&cli.BoolFlag{
+						Name:    insecureFlag[0],
+						Aliases: insecureFlag[1:],
+						Usage:   "skip verification of the host's certificate",
+					},
+					&cli.StringFlag{
+						Name:  "proxy",
+						Usage: "route this benchmark through a proxy, ignoring any saved proxy settings",
+					},
+					&cli.StringSliceFlag{
+						Name:  varFlag,
+						Usage: "set a template variable in the form name=value, used as {{.name}}",
+					},
+				},
+				Action: action.Bench(cfgPath, httpClient),
+			},
+			{
+				Name:      "mock",
+				Usage:     "serve the example responses of an application's requests",
+				ArgsUsage: "[app]",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "port",
+						Value: 9000,
+						Usage: "port to listen on",
+					},
+					&cli.StringFlag{
+						Name:  "latency",
+						Usage: "wait this long before responses that do not have a latency of their own",
+					},
+				},
+				Action: action.Mock(cfgPath),
+			},
+			{
+				Name:  "record",
+				Usage: "proxy traffic to a server and save every distinct request to an application",
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    appFlag[0],
+						Aliases: appFlag[1:],
+						Usage:   "specify an application",
+					},
+					&cli.StringFlag{
+						Name:  "listen",
+						Value: "localhost:8081",
+						Usage: "address to listen on",
+					},
+					&cli.StringFlag{
+						Name:        "target",
+						Usage:       "URL to forward traffic to",
+						DefaultText: "the application's host",
+					},
+				},
+				Action: action.Record(cfgPath),
+			},
+			{
+				Name:  "history",
+				Usage: "view and replay previous calls",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "list previous calls, oldest first",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    appFlag[0],
+								Aliases: appFlag[1:],
+								Usage:   "only list calls to an application",
+							},
+							&cli.IntFlag{
+								Name:    "limit",
+								Aliases: []string{"n"},
+								Usage:   "only list the most recent calls",
+							},
+						},
+						Action: action.HistoryList(cfgPath),
+					},
+					{
+						Name:   "show",
+						Usage:  "show the request and response of a previous call",
+						Action: action.HistoryShow(cfgPath),
+					},
+					{
+						Name:  "replay",
+						Usage: "send the exact request of a previous call again",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:    insecureFlag[0],
+								Aliases: insecureFlag[1:],
+								Usage:   "skip verification of the host's certificate",
+							},
+							&cli.StringFlag{
+								Name:  "proxy",
+								Usage: "route this call through a proxy, ignoring any saved proxy settings",
+							},
+						}, outputFlags()...),
+						Action: action.HistoryReplay(cfgPath, httpClient),
+					},
+					{
+						Name:      "diff",
+						Usage:     "compare the responses of two previous calls",
+						ArgsUsage: "<id> <id>",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  ignoreFlag,
+								Usage: "skip a JSON path such as .meta.timestamp when comparing, may be repeated",
+							},
+						},
+						Action: action.HistoryDiff(cfgPath),
+					},
+					{
+						Name:  "clear",
+						Usage: "delete every call from the history",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  confirmFlag,
+								Usage: "skips the confirmation request and immediately deletes the history",
+							},
+						},
+						Action: action.HistoryClear(cfgPath),
+					},
+				},
+			},
+			{
+				Name:      "call",
+				Usage:     "make one or more requests",
+				ArgsUsage: "<request>...",
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:    appFlag[0],
 						Aliases: appFlag[1:],
@@ -310,7 +953,102 @@ Type 'sp9rk help' for more information.`)
 						Aliases: noRedirectFlag[1:],
 						Usage:   "follow redirects",
 					},
-				},
+					&cli.BoolFlag{
+						Name:    insecureFlag[0],
+						Aliases: insecureFlag[1:],
+						Usage:   "skip verification of the host's certificate",
+					},
+					&cli.StringFlag{
+						Name:  "proxy",
+						Usage: "route this call through a proxy, ignoring any saved proxy settings",
+					},
+					&cli.StringSliceFlag{
+						Name:    queryFlag[0],
+						Aliases: queryFlag[1:],
+						Usage:   "set a query parameter for this call in the form name=value",
+					},
+					&cli.StringSliceFlag{
+						Name:  varFlag,
+						Usage: "set a template variable in the form name=value, used as {{.name}}",
+					},
+					&cli.BoolFlag{
+						Name:  "snapshot",
+						Usage: "save the response as the request's snapshot",
+					},
+					&cli.BoolFlag{
+						Name:  "compare",
+						Usage: "compare the response against the request's snapshot and fail if they differ",
+					},
+					&cli.StringSliceFlag{
+						Name:  ignoreFlag,
+						Usage: "skip a JSON path such as .meta.timestamp or .items[].id when comparing, may be repeated",
+					},
+					&cli.StringFlag{
+						Name:    "output-file",
+						Aliases: []string{"o"},
+						Usage:   "save the response body to a file instead of printing it",
+					},
+					&cli.BoolFlag{
+						Name:    "remote-name",
+						Aliases: []string{"O"},
+						Usage:   "save the response body to a file named after the last segment of the URL",
+					},
+					&cli.BoolFlag{
+						Name:    "continue",
+						Aliases: []string{"C"},
+						Usage:   "resume a partial download of --output-file or --remote-name",
+					},
+					&cli.BoolFlag{
+						Name:    "interactive",
+						Aliases: []string{"i"},
+						Usage:   "send every line read from stdin over a websocket",
+					},
+					&cli.BoolFlag{
+						Name:  "stream",
+						Usage: "print the response as it arrives, parsing Server-Sent Events into one JSON object per line",
+					},
+					&cli.IntFlag{
+						Name:  "max-events",
+						Usage: "stop streaming after this many events, websocket messages, gRPC responses or lines",
+					},
+					&cli.StringFlag{
+						Name:  "stream-timeout",
+						Usage: "stop streaming after this long (e.g. 30s)",
+					},
+					&cli.StringFlag{
+						Name:  "select",
+						Usage: "only print part of the response, e.g. .data.items[0].id for JSON or //item/@id for XML",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "print the request exactly as it would be sent without sending it",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "call every request of the application",
+					},
+					&cli.IntFlag{
+						Name:  "parallel",
+						Value: 4,
+						Usage: "number of calls made at once when calling several requests or --data rows",
+					},
+					&cli.BoolFlag{
+						Name:  "interleave",
+						Usage: "print the output of several requests as it arrives instead of one request at a time",
+					},
+					&cli.StringFlag{
+						Name:  "rate",
+						Usage: "limit how often calls start when calling several, e.g. 10/s or 30/m",
+					},
+					&cli.StringFlag{
+						Name:  "data",
+						Usage: "call the request once for every row of a CSV or JSON file, binding columns as template variables",
+					},
+					&cli.StringFlag{
+						Name:  "results",
+						Usage: "write the status and captured values of every --data row to a CSV or JSON file",
+					},
+				}, append(retryFlags(), outputFlags()...)...),
 				Action: action.Call(cfgPath, httpClient),
 			},
 		},
@@ -26,5 +26,6 @@ func main() {
 
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprint(os.Stderr, err)
+		os.Exit(1)
 	}
 }